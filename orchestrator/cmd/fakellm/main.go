@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/fakellm"
+)
+
+func main() {
+	mode := flag.String("mode", "replay", "Server mode: 'record' (proxy to upstream and save fixtures) or 'replay' (serve fixtures)")
+	addr := flag.String("addr", "127.0.0.1:8089", "Listen address")
+	fixtures := flag.String("fixtures", "testdata/fakellm", "Fixture directory")
+	upstream := flag.String("upstream", "https://api.deepseek.com/v1/chat/completions", "Upstream chat completions URL (record mode)")
+	flag.Parse()
+
+	srv, err := fakellm.New(fakellm.Config{
+		Mode:        fakellm.Mode(*mode),
+		FixtureDir:  *fixtures,
+		UpstreamURL: *upstream,
+	})
+	if err != nil {
+		log.Fatalf("fakellm: %v", err)
+	}
+
+	log.Printf("fakellm %s server listening on http://%s (fixtures: %s)", *mode, *addr, *fixtures)
+	log.Fatal(http.ListenAndServe(*addr, srv))
+}
@@ -3,21 +3,28 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/agents"
 	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/config"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/ctxbudget"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/gocode"
 	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/loop"
 	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/project"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/prompts"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/retrieval"
 	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/task"
 )
 
 func main() {
 	mode := flag.String("mode", "continuous", "Run mode: 'continuous' (process all tasks) or 'single' (process one task)")
 	taskID := flag.String("task", "", "Task ID to run (single mode only)")
+	noCache := flag.Bool("no-cache", false, "Disable the response cache for this run")
 	flag.Parse()
 
 	cfg := config.Load()
@@ -35,26 +42,195 @@ func main() {
 	log.Printf("Backend found: %v", paths.HasBackend())
 	log.Printf("Mobile found: %v", paths.HasMobile())
 	log.Printf("Task file found: %v", paths.HasTaskFile())
+	log.Printf("Engine provider: %s", cfg.EngineProvider)
+	log.Printf("Debugger provider: %s", cfg.DebuggerProvider)
 
-	if cfg.EngineAPIKey == "" {
-		log.Fatal("ENGINE_API_KEY is required")
+	// Initialize providers
+	engineProvider, engineModel, err := newAgentProvider(cfg, config.ProviderSpec{
+		Provider: cfg.EngineProvider,
+		Model:    cfg.EngineModel,
+		APIKey:   cfg.EngineAPIKey,
+		APIURL:   cfg.EngineAPIURL,
+	}, cfg.EngineFallbacks)
+	if err != nil {
+		log.Fatalf("Engine provider: %v", err)
+	}
+
+	debuggerProvider, debuggerModel, err := newAgentProvider(cfg, config.ProviderSpec{
+		Provider: cfg.DebuggerProvider,
+		Model:    cfg.DebuggerModel,
+		APIKey:   cfg.DebuggerAPIKey,
+		APIURL:   cfg.DebuggerAPIURL,
+	}, cfg.DebuggerFallbacks)
+	if err != nil {
+		log.Fatalf("Debugger provider: %v", err)
+	}
+	// Cache keys and context budgets need the model actually called, which
+	// is the provider's default when none is configured.
+	cfg.EngineModel, cfg.DebuggerModel = engineModel, debuggerModel
+	log.Printf("Engine model: %s, Debugger model: %s", engineModel, debuggerModel)
+
+	var cache *agents.ResponseCache
+	if cfg.CacheEnabled && !*noCache {
+		cache, err = agents.NewResponseCache(agents.CacheConfig{
+			Dir:                     cfg.CacheDir,
+			TTL:                     cfg.CacheTTL,
+			MaxBytes:                int64(cfg.CacheMaxMB) << 20,
+			AllowNonZeroTemperature: cfg.CacheAllowSampled,
+		})
+		if err != nil {
+			log.Fatalf("Response cache: %v", err)
+		}
+		engineProvider = agents.NewCachedProvider(engineProvider, cfg.EngineModel, cache)
+		debuggerProvider = agents.NewCachedProvider(debuggerProvider, cfg.DebuggerModel, cache)
+		log.Printf("Response cache: %s", cfg.CacheDir)
+	}
+
+	prices, err := agents.ParsePriceTable(cfg.ModelPrices)
+	if err != nil {
+		log.Fatalf("MODEL_PRICES: %v", err)
+	}
+	ledger := agents.NewLedger(prices, cfg.TaskBudgetUSD, cfg.RunBudgetUSD)
+
+	promptSet, err := prompts.Load(paths.Root)
+	if err != nil {
+		log.Fatalf("Prompt templates: %v", err)
+	}
+	for _, tmpl := range promptSet.Templates() {
+		log.Printf("Prompt %s@%s (%s)", tmpl.Name, tmpl.Version, tmpl.Source)
+	}
+
+	// The Debugger's prompt lists the commands the policy lets it run.
+	allow, deny := cfg.FixCommandAllow, cfg.FixCommandDeny
+	if len(allow) == 0 {
+		allow = agents.DefaultAllowedCommands
+	}
+	if len(deny) == 0 {
+		deny = agents.DefaultDeniedCommands
+	}
+	commandPolicy := agents.NewCommandPolicy(allow, deny)
+
+	engineSystem, err := promptSet.Render(prompts.EngineSystem, prompts.Data{})
+	if err != nil {
+		log.Fatalf("Prompt templates: %v", err)
+	}
+	debuggerSystem, err := promptSet.Render(prompts.DebuggerSystem, prompts.Data{AllowedCommands: commandPolicy.Allowed()})
+	if err != nil {
+		log.Fatalf("Prompt templates: %v", err)
+	}
+	editSystem, err := promptSet.Render(prompts.EditSystem, prompts.Data{})
+	if err != nil {
+		log.Fatalf("Prompt templates: %v", err)
 	}
 
 	// Initialize agents
-	engine := agents.NewEngine(cfg.EngineAPIKey, cfg.EngineAPIURL, cfg.EngineModel)
+	newEngine := func(systemPrompt string) *agents.Engine {
+		engine := agents.NewEngine(engineProvider)
+		engine.SetSystemPrompt(systemPrompt)
+		engine.SetStreaming(cfg.EngineStream)
+		engine.SetLedger(ledger)
+		engine.SetRetryPolicy(agents.RetryPolicy{
+			MaxAttempts: cfg.EngineMaxAttempts,
+			BaseDelay:   cfg.EngineRetryBaseDelay,
+			MaxDelay:    cfg.EngineRetryMaxDelay,
+			Jitter:      0.2,
+		})
+		return engine
+	}
+	engine := newEngine(engineSystem)
 	executioner := agents.NewExecutioner(paths.Root)
-	debugger := agents.NewDebugger(cfg.DebuggerAPIKey, cfg.DebuggerAPIURL, cfg.DebuggerModel)
+	executioner.SetKillGrace(cfg.ExecKillGrace)
+	executioner.SetMaxOutputBytes(cfg.ExecMaxOutputBytes)
+
+	nativeEngine := newEngine(editSystem)
+	backends := map[string]agents.ExecutionBackend{
+		agents.BackendClaude: agents.NewClaudeBackend(executioner),
+		agents.BackendNative: agents.NewNativeBackend(nativeEngine, paths.Root),
+	}
+	if cfg.ExecCLICommand != "" {
+		cli, err := agents.NewCLIBackend(executioner, cfg.ExecCLICommand)
+		if err != nil {
+			log.Fatalf("EXEC_CLI_COMMAND: %v", err)
+		}
+		backends[agents.BackendCLI] = cli
+	}
+	if _, ok := backends[cfg.ExecBackend]; !ok {
+		log.Fatalf("EXEC_BACKEND: backend %q is unknown or not configured", cfg.ExecBackend)
+	}
+	log.Printf("Execution backend: %s", cfg.ExecBackend)
+	debugger := agents.NewDebugger(debuggerProvider)
+	debugger.SetSystemPrompt(debuggerSystem)
+	debugger.SetStreaming(cfg.DebuggerStream)
+	debugger.SetLedger(ledger)
+	debugger.SetRetryPolicy(agents.RetryPolicy{
+		MaxAttempts: cfg.DebuggerMaxAttempts,
+		BaseDelay:   cfg.DebuggerRetryBaseDelay,
+		MaxDelay:    cfg.DebuggerRetryMaxDelay,
+		Jitter:      0.2,
+	})
+	debugger.SetRepairTurns(cfg.DebuggerRepairTurns)
+	if cfg.DebuggerTools {
+		debugger.EnableTools(paths.Root, cfg.DebuggerMaxToolRounds)
+	}
+
+	var retriever *retrieval.Index
+	if cfg.RetrievalEnabled {
+		retriever, err = retrieval.Build(paths.Root)
+		if err != nil {
+			log.Fatalf("Retrieval index: %v", err)
+		}
+		files, chunks := retriever.Stats()
+		log.Printf("Retrieval index: %d files, %d chunks", files, chunks)
+	}
+
+	var goCode *gocode.Index
+	if cfg.GoContextEnabled && paths.HasBackend() {
+		start := time.Now()
+		goCode, err = gocode.Load(paths.Backend)
+		if err != nil {
+			log.Fatalf("Go code index: %v", err)
+		}
+		log.Printf("Go code index: %s (%s)", paths.Backend, time.Since(start).Round(time.Millisecond))
+	}
 
 	agentSet := &loop.AgentSet{
 		Engine:      engine,
 		Executioner: executioner,
 		Debugger:    debugger,
+
+		Backends:       backends,
+		DefaultBackend: cfg.ExecBackend,
 	}
 
+	// Initialize task manager
+	taskMgr := task.NewManager(paths.TaskFile)
+
 	loopCfg := &loop.LoopConfig{
 		MaxRetries:  cfg.MaxRetries,
 		TestCommand: cfg.TestCommandGo,
 		ProjectDir:  paths.Root,
+		Ledger:      ledger,
+		Tasks:       taskMgr,
+		Prompts:     promptSet,
+
+		EngineContextTokens:      promptBudget(cfg, cfg.EngineModel, engine.MaxTokens(), engine.SystemPrompt()),
+		DebuggerContextTokens:    promptBudget(cfg, cfg.DebuggerModel, debugger.MaxTokens(), debugger.SystemPrompt()),
+		ExecutionerContextTokens: cfg.ExecutionerContext,
+		BackendContextTokens: map[string]int{
+			// The native backend prompts the Engine and adds file contents.
+			agents.BackendNative: max(promptBudget(cfg, cfg.EngineModel, nativeEngine.MaxTokens(), nativeEngine.SystemPrompt())-agents.NativeContextTokens, 1000),
+		},
+		DebugMemoryTurns: cfg.DebuggerMemoryTurns,
+		FreshSessions:    cfg.ExecFreshSessions,
+
+		Retriever:       retriever,
+		RetrievalTopK:   cfg.RetrievalTopK,
+		RetrievalTokens: cfg.RetrievalTokens,
+
+		GoCode:          goCode,
+		GoContextTokens: cfg.GoContextTokens,
+
+		CommandPolicy: commandPolicy,
 	}
 
 	// Setup context with cancellation
@@ -69,9 +245,6 @@ func main() {
 		cancel()
 	}()
 
-	// Initialize task manager
-	taskMgr := task.NewManager(paths.TaskFile)
-
 	switch *mode {
 	case "single":
 		if *taskID == "" {
@@ -92,6 +265,9 @@ func main() {
 			log.Fatalf("Task %s not found", *taskID)
 		}
 		if err := loop.RunAutonomousLoop(ctx, target, agentSet, loopCfg); err != nil {
+			if setErr := taskMgr.SetError(target.ID, err.Error()); setErr != nil {
+				log.Printf("Failed to record error: %v", setErr)
+			}
 			log.Fatalf("Task failed: %v", err)
 		}
 		if err := taskMgr.UpdateStatus(target.ID, "completed"); err != nil {
@@ -107,5 +283,80 @@ func main() {
 		log.Fatalf("Unknown mode: %s", *mode)
 	}
 
+	if cache != nil {
+		hits, misses := cache.Stats()
+		log.Printf("Response cache: %d hits, %d misses", hits, misses)
+	}
+	run := ledger.RunTotals()
+	log.Printf("Run usage: %d calls, %d prompt + %d completion tokens, $%.4f",
+		run.Calls, run.PromptTokens, run.CompletionTokens, run.CostUSD)
 	log.Println("Orchestrator finished.")
 }
+
+// promptBudget is the part of a model's context window left for the user
+// prompt after the system prompt and the completion.
+func promptBudget(cfg *config.Config, model string, maxTokens int, systemPrompt string) int {
+	window := cfg.DefaultContextWindow
+	if n, ok := cfg.ContextWindows[model]; ok {
+		window = n
+	}
+	return max(window-maxTokens-ctxbudget.EstimateTokens(systemPrompt), 1000)
+}
+
+// newAgentProvider creates the primary provider for an agent and, if
+// fallbacks are configured, chains them behind it with circuit breakers.
+// Providers with a configured rate limit share one limiter per account. It
+// also returns the primary's model, resolved to the provider default if the
+// spec has none.
+func newAgentProvider(cfg *config.Config, primary config.ProviderSpec, fallbacks []config.ProviderSpec) (agents.Provider, string, error) {
+	var entries []agents.FallbackEntry
+	model := primary.Model
+	for _, spec := range append([]config.ProviderSpec{primary}, fallbacks...) {
+		p, err := agents.NewProvider(spec.Provider, agents.ProviderConfig{
+			APIKey:      spec.APIKey,
+			APIURL:      spec.APIURL,
+			Model:       spec.Model,
+			IdleTimeout: cfg.StreamIdleTimeout,
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", spec.Provider, err)
+		}
+		if r, ok := p.(agents.ModelReporter); ok {
+			spec.Model = r.Model()
+		}
+		if len(entries) == 0 {
+			model = spec.Model
+		}
+
+		if checker, ok := p.(agents.ModelChecker); ok {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			err := checker.CheckModel(ctx)
+			cancel()
+			if err != nil {
+				return nil, "", fmt.Errorf("%s: %w", spec.Provider, err)
+			}
+		}
+
+		if limit, ok := cfg.RateLimits[spec.Provider]; ok {
+			limiter := agents.SharedRateLimiter(spec.Provider, spec.APIURL, spec.APIKey, agents.RateLimit{
+				RPM:         limit.RPM,
+				TPM:         limit.TPM,
+				MaxInFlight: limit.MaxInFlight,
+			})
+			p = agents.NewRateLimitedProvider(p, limiter)
+		}
+
+		label := spec.Provider
+		if spec.Model != "" {
+			label += ":" + spec.Model
+		}
+		entries = append(entries, agents.FallbackEntry{Label: label, Provider: p})
+	}
+
+	if len(entries) == 1 {
+		return entries[0].Provider, model, nil
+	}
+	chain := agents.NewFallbackProvider(entries, cfg.BreakerThreshold, cfg.BreakerCooldown)
+	log.Printf("Provider chain: %s", chain.Name())
+	return chain, model, nil
+}
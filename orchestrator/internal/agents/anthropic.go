@@ -0,0 +1,342 @@
+package agents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const anthropicVersion = "2023-06-01"
+
+func init() {
+	RegisterProvider(ProviderAnthropic, newAnthropicProvider)
+}
+
+// anthropicRequest is the request body for the Anthropic Messages API.
+type anthropicRequest struct {
+	Model       string               `json:"model"`
+	System      string               `json:"system,omitempty"`
+	Messages    []anthropicMessage   `json:"messages"`
+	Temperature float64              `json:"temperature,omitempty"`
+	MaxTokens   int                  `json:"max_tokens"`
+	Stream      bool                 `json:"stream,omitempty"`
+	Tools       []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+// anthropicMessage is a conversation turn made of content blocks.
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock is a text, tool_use or tool_result content block.
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"` // auto | any | tool
+}
+
+// anthropicResponse is the response from the Anthropic Messages API.
+type anthropicResponse struct {
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func (u anthropicUsage) toUsage() Usage {
+	return Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+// anthropicStreamEvent covers the fields used from Messages API stream events.
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Message struct {
+		Model string         `json:"model"`
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Usage anthropicUsage `json:"usage"`
+}
+
+// anthropicProvider speaks the Anthropic Messages API.
+type anthropicProvider struct {
+	apiKey      string
+	apiURL      string
+	model       string
+	idleTimeout time.Duration
+	client      *http.Client
+}
+
+func newAnthropicProvider(cfg ProviderConfig) (Provider, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("anthropic provider requires an API key")
+	}
+
+	return &anthropicProvider{
+		apiKey:      cfg.APIKey,
+		apiURL:      orDefault(cfg.APIURL, "https://api.anthropic.com/v1/messages"),
+		model:       orDefault(cfg.Model, "claude-sonnet-4-5"),
+		idleTimeout: cfg.IdleTimeout,
+		client:      &http.Client{Timeout: 120 * time.Second},
+	}, nil
+}
+
+func (p *anthropicProvider) Name() string {
+	return ProviderAnthropic
+}
+
+func (p *anthropicProvider) Model() string {
+	return p.model
+}
+
+func (p *anthropicProvider) Chat(ctx context.Context, reqBody *ChatRequest) (*ChatResponse, error) {
+	resp, err := p.do(ctx, p.client, reqBody, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &TransportError{Err: fmt.Errorf("read response: %w", err)}
+	}
+
+	var msgResp anthropicResponse
+	if err := json.Unmarshal(respBody, &msgResp); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+
+	msg := ChatMessage{Role: "assistant"}
+	var text strings.Builder
+	for _, block := range msgResp.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: ToolCallFunction{Name: block.Name, Arguments: string(block.Input)},
+			})
+		}
+	}
+	msg.Content = text.String()
+
+	if msg.Content == "" && len(msg.ToolCalls) == 0 {
+		return nil, fmt.Errorf("no text content in API response")
+	}
+
+	return &ChatResponse{
+		Model: msgResp.Model,
+		Choices: []ChatChoice{{
+			Message:      msg,
+			FinishReason: msgResp.StopReason,
+		}},
+		Usage: msgResp.Usage.toUsage(),
+	}, nil
+}
+
+// ChatStream requests a server-sent event stream. The HTTP client has no
+// overall timeout; the stream is aborted only when it goes idle.
+func (p *anthropicProvider) ChatStream(ctx context.Context, reqBody *ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	ctx, idle, stop := newIdleTimer(ctx, p.idleTimeout)
+	defer stop()
+
+	resp, err := p.do(ctx, http.DefaultClient, reqBody, true)
+	if err != nil {
+		return nil, idle.Err(err)
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var stopReason string
+	var usage anthropicUsage
+	model := orDefault(reqBody.Model, p.model)
+	done := false
+	err = readSSE(&touchReader{r: resp.Body, timer: idle}, func(_, data string) error {
+		var ev anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return fmt.Errorf("unmarshal stream event: %w", err)
+		}
+
+		switch ev.Type {
+		case "message_start":
+			usage.InputTokens = ev.Message.Usage.InputTokens
+			if ev.Message.Model != "" {
+				model = ev.Message.Model
+			}
+		case "content_block_delta":
+			if ev.Delta.Type == "text_delta" && ev.Delta.Text != "" {
+				content.WriteString(ev.Delta.Text)
+				onDelta(ev.Delta.Text)
+			}
+		case "message_delta":
+			if ev.Delta.StopReason != "" {
+				stopReason = ev.Delta.StopReason
+			}
+			usage.OutputTokens = ev.Usage.OutputTokens
+		case "message_stop":
+			done = true
+			return io.EOF
+		case "error":
+			return fmt.Errorf("stream error %s: %s", ev.Error.Type, ev.Error.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, &TransportError{Err: idle.Err(fmt.Errorf("read stream: %w", err))}
+	}
+	if !done {
+		return nil, &TransportError{Err: fmt.Errorf("stream ended before message_stop after %d bytes", content.Len())}
+	}
+
+	return &ChatResponse{
+		Model: model,
+		Choices: []ChatChoice{{
+			Message:      ChatMessage{Role: "assistant", Content: content.String()},
+			FinishReason: stopReason,
+		}},
+		Usage: usage.toUsage(),
+	}, nil
+}
+
+// do converts the request to the Messages API format and sends it.
+func (p *anthropicProvider) do(ctx context.Context, client *http.Client, reqBody *ChatRequest, stream bool) (*http.Response, error) {
+	system, messages := toAnthropicMessages(reqBody.Messages)
+
+	areq := anthropicRequest{
+		Model:       orDefault(reqBody.Model, p.model),
+		System:      system,
+		Messages:    messages,
+		Temperature: reqBody.Temperature,
+		MaxTokens:   reqBody.MaxTokens,
+		Stream:      stream,
+	}
+	for _, t := range reqBody.Tools {
+		areq.Tools = append(areq.Tools, anthropicTool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: t.Function.Parameters,
+		})
+	}
+	switch reqBody.ToolChoice {
+	case ToolChoiceAuto:
+		areq.ToolChoice = &anthropicToolChoice{Type: "auto"}
+	case ToolChoiceRequired:
+		areq.ToolChoice = &anthropicToolChoice{Type: "any"}
+	case ToolChoiceNone:
+		areq.Tools = nil
+	}
+
+	body, err := json.Marshal(areq)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.apiURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &TransportError{Err: fmt.Errorf("API call failed: %w", err)}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, newAPIError(resp, respBody)
+	}
+
+	return resp, nil
+}
+
+// toAnthropicMessages lifts system messages into the top-level system prompt,
+// turns tool calls into tool_use blocks and merges consecutive tool results
+// into a single user turn. Messages with neither text nor tool calls are
+// dropped, since the API rejects empty content.
+func toAnthropicMessages(in []ChatMessage) (string, []anthropicMessage) {
+	var system []string
+	var out []anthropicMessage
+
+	for _, m := range in {
+		switch m.Role {
+		case "system":
+			system = append(system, m.Content)
+
+		case "tool":
+			block := anthropicBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content}
+			if n := len(out); n > 0 && out[n-1].Role == "user" && len(out[n-1].Content) > 0 && out[n-1].Content[0].Type == "tool_result" {
+				out[n-1].Content = append(out[n-1].Content, block)
+				continue
+			}
+			out = append(out, anthropicMessage{Role: "user", Content: []anthropicBlock{block}})
+
+		default:
+			msg := anthropicMessage{Role: m.Role}
+			if m.Content != "" {
+				msg.Content = append(msg.Content, anthropicBlock{Type: "text", Text: m.Content})
+			}
+			for _, call := range m.ToolCalls {
+				input := json.RawMessage(call.Function.Arguments)
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
+				msg.Content = append(msg.Content, anthropicBlock{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  call.Function.Name,
+					Input: input,
+				})
+			}
+			if len(msg.Content) > 0 {
+				out = append(out, msg)
+			}
+		}
+	}
+
+	return strings.Join(system, "\n\n"), out
+}
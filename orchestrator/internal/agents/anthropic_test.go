@@ -0,0 +1,63 @@
+package agents
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToAnthropicMessages(t *testing.T) {
+	call := ToolCall{ID: "c1", Type: "function", Function: ToolCallFunction{Name: "grep", Arguments: `{"pattern":"x"}`}}
+	tests := []struct {
+		name   string
+		in     []ChatMessage
+		system string
+		want   string
+	}{
+		{
+			name:   "system lifted",
+			in:     []ChatMessage{{Role: "system", Content: "a"}, {Role: "system", Content: "b"}, {Role: "user", Content: "hi"}},
+			system: "a\n\nb",
+			want:   `[{"role":"user","content":[{"type":"text","text":"hi"}]}]`,
+		},
+		{
+			name: "tool calls and merged results",
+			in: []ChatMessage{
+				{Role: "user", Content: "find x"},
+				{Role: "assistant", ToolCalls: []ToolCall{call, {ID: "c2", Function: ToolCallFunction{Name: "read_file"}}}},
+				{Role: "tool", ToolCallID: "c1", Content: "a.go:1: x"},
+				{Role: "tool", ToolCallID: "c2", Content: "package a"},
+			},
+			want: `[{"role":"user","content":[{"type":"text","text":"find x"}]},` +
+				`{"role":"assistant","content":[{"type":"tool_use","id":"c1","name":"grep","input":{"pattern":"x"}},{"type":"tool_use","id":"c2","name":"read_file","input":{}}]},` +
+				`{"role":"user","content":[{"type":"tool_result","tool_use_id":"c1","content":"a.go:1: x"},{"type":"tool_result","tool_use_id":"c2","content":"package a"}]}]`,
+		},
+		{
+			name: "tool result after a user text is its own turn",
+			in:   []ChatMessage{{Role: "user", Content: "hi"}, {Role: "tool", ToolCallID: "c1", Content: "ok"}},
+			want: `[{"role":"user","content":[{"type":"text","text":"hi"}]},{"role":"user","content":[{"type":"tool_result","tool_use_id":"c1","content":"ok"}]}]`,
+		},
+		{
+			name: "empty messages dropped",
+			in: []ChatMessage{
+				{Role: "user", Content: ""},
+				{Role: "tool", ToolCallID: "c1", Content: "ok"},
+				{Role: "assistant", Content: ""},
+				{Role: "user", Content: "next"},
+			},
+			want: `[{"role":"user","content":[{"type":"tool_result","tool_use_id":"c1","content":"ok"}]},{"role":"user","content":[{"type":"text","text":"next"}]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			system, out := toAnthropicMessages(tt.in)
+			if system != tt.system {
+				t.Errorf("system = %q, want %q", system, tt.system)
+			}
+			got, _ := json.Marshal(out)
+			if string(got) != tt.want {
+				t.Errorf("messages =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/ctxbudget"
+)
+
+// Execution backend names.
+const (
+	BackendClaude = "claude"
+	BackendCLI    = "cli"
+	BackendNative = "native"
+)
+
+// ExecRequest is a coding prompt for an execution backend.
+type ExecRequest struct {
+	Prompt string
+	// SessionID is a session to resume; backends without sessions ignore it.
+	SessionID string
+}
+
+// ExecutionBackend carries out execute and fix prompts in the project tree.
+// The summary is returned even when execution fails.
+type ExecutionBackend interface {
+	Name() string
+	Execute(ctx context.Context, req ExecRequest) (*ExecutionSummary, error)
+}
+
+// ExecutionSummary is what an execution backend did.
+type ExecutionSummary struct {
+	SessionID   string
+	Result      string // the agent's final message
+	FilesEdited []string
+	ToolUses    map[string]int
+	Commands    []string // Bash commands run
+	ToolErrors  int
+	Turns       int
+	CostUSD     float64
+	Usage       Usage
+	IsError     bool
+	Duration    time.Duration
+
+	// Command is the underlying process result, if the backend spawned one.
+	Command *CommandResult
+}
+
+// String renders the summary for prompts and logs.
+func (s *ExecutionSummary) String() string {
+	var b strings.Builder
+	if s.Result != "" {
+		b.WriteString(strings.TrimSpace(s.Result) + "\n\n")
+	}
+	if len(s.FilesEdited) > 0 {
+		fmt.Fprintf(&b, "Files edited: %s\n", strings.Join(s.FilesEdited, ", "))
+	}
+	if len(s.Commands) > 0 {
+		fmt.Fprintf(&b, "Commands run: %s\n", strings.Join(s.Commands, "; "))
+	}
+	if len(s.ToolUses) > 0 {
+		names := make([]string, 0, len(s.ToolUses))
+		for name := range s.ToolUses {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			names[i] = fmt.Sprintf("%s×%d", name, s.ToolUses[name])
+		}
+		fmt.Fprintf(&b, "Tools: %s (%d failed)\n", strings.Join(names, ", "), s.ToolErrors)
+	}
+	fmt.Fprintf(&b, "Turns: %d, cost: $%.4f", s.Turns, s.CostUSD)
+	if s.IsError {
+		b.WriteString(", ended with an error")
+	}
+	return b.String()
+}
+
+// ClaudeBackend runs the Claude CLI in print mode with full tool access.
+type ClaudeBackend struct {
+	exec *Executioner
+}
+
+func NewClaudeBackend(exec *Executioner) *ClaudeBackend {
+	return &ClaudeBackend{exec: exec}
+}
+
+func (b *ClaudeBackend) Name() string {
+	return BackendClaude
+}
+
+// Execute runs the prompt, resuming req.SessionID if set so the CLI keeps
+// the context of earlier work. A session that can no longer be resumed is
+// replaced by a new one. Stream-json events are logged as they arrive.
+func (b *ClaudeBackend) Execute(ctx context.Context, req ExecRequest) (*ExecutionSummary, error) {
+	if req.SessionID == "" {
+		return b.run(ctx, req.Prompt, "")
+	}
+
+	log.Printf("[EXECUTIONER] Resuming session %s", req.SessionID)
+	summary, err := b.run(ctx, req.Prompt, req.SessionID)
+	if err != nil && summary.SessionID == "" && ctx.Err() == nil {
+		log.Printf("[EXECUTIONER] Could not resume session %s (%v); starting a new one", req.SessionID, err)
+		return b.run(ctx, req.Prompt, "")
+	}
+	return summary, err
+}
+
+func (b *ClaudeBackend) run(ctx context.Context, prompt, sessionID string) (*ExecutionSummary, error) {
+	stream := newClaudeStream(logExecEvent)
+	lines := &lineWriter{fn: stream.Line}
+
+	args := []string{"-p", "--verbose", "--output-format", "stream-json", "--dangerously-skip-permissions"}
+	if sessionID != "" {
+		args = append(args, "--resume", sessionID)
+	}
+	res, err := b.exec.run(ctx, "claude", lines, "claude", append(args, prompt)...)
+	lines.Flush()
+
+	summary := &stream.summary
+	summary.Command = res
+	summary.Duration = res.Duration
+	if !stream.done {
+		// No result event: keep whatever the CLI printed.
+		summary.Result = res.Output()
+	}
+	if err == nil && summary.IsError {
+		err = fmt.Errorf("claude CLI reported an error: %s", lastLine(summary.Result))
+	}
+	return summary, err
+}
+
+// logExecEvent writes a Claude CLI event to the orchestrator log.
+func logExecEvent(ev ExecEvent) {
+	switch ev.Kind {
+	case ExecEventInit:
+		log.Printf("[EXECUTIONER] Session %s (%s)", ev.SessionID, ev.Model)
+	case ExecEventText:
+		if text := strings.TrimSpace(ev.Text); text != "" {
+			log.Printf("[EXECUTIONER] %s", ctxbudget.Truncate(text, 60))
+		}
+	case ExecEventToolUse:
+		if _, ok := fileEditTools[ev.Tool]; !ok {
+			log.Printf("[EXECUTIONER] %s %s", ev.Tool, describeToolInput(ev.Input))
+		}
+	case ExecEventFileEdit:
+		log.Printf("[EXECUTIONER] %s %s", ev.Tool, ev.File)
+	case ExecEventToolResult:
+		if ev.IsError {
+			log.Printf("[EXECUTIONER] %s failed: %s", ev.Tool, lastLine(ev.Text))
+		}
+	case ExecEventResult:
+		log.Printf("[EXECUTIONER] Finished in %s: %d turns, %d+%d tokens, $%.4f (error: %t)",
+			ev.Duration.Round(time.Second), ev.Turns, ev.Usage.PromptTokens, ev.Usage.CompletionTokens, ev.CostUSD, ev.IsError)
+	}
+}
+
+// describeToolInput picks the most telling argument of a tool call.
+func describeToolInput(raw json.RawMessage) string {
+	var input map[string]any
+	json.Unmarshal(raw, &input)
+	for _, key := range []string{"command", "file_path", "path", "pattern", "url", "description"} {
+		if v, ok := input[key].(string); ok {
+			return ctxbudget.Truncate(v, 30)
+		}
+	}
+	return ""
+}
+
+// CLIBackend runs any command-line coding agent, such as aider, from a
+// command template. The template is split on whitespace; {prompt} is
+// replaced by the prompt and {prompt_file} by the path of a temporary file
+// holding it, e.g. "aider --yes-always --no-auto-commits --message {prompt}".
+type CLIBackend struct {
+	exec *Executioner
+	args []string
+}
+
+func NewCLIBackend(exec *Executioner, template string) (*CLIBackend, error) {
+	args := strings.Fields(template)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command template")
+	}
+	if !strings.Contains(template, "{prompt}") && !strings.Contains(template, "{prompt_file}") {
+		return nil, fmt.Errorf("command template %q has no {prompt} or {prompt_file}", template)
+	}
+	return &CLIBackend{exec: exec, args: args}, nil
+}
+
+func (b *CLIBackend) Name() string {
+	return BackendCLI
+}
+
+func (b *CLIBackend) Execute(ctx context.Context, req ExecRequest) (*ExecutionSummary, error) {
+	summary := &ExecutionSummary{ToolUses: make(map[string]int)}
+
+	var promptFile string
+	if strings.Contains(strings.Join(b.args, " "), "{prompt_file}") {
+		f, err := os.CreateTemp("", "orchestrator-prompt-*.md")
+		if err != nil {
+			return summary, fmt.Errorf("write prompt file: %w", err)
+		}
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(req.Prompt)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return summary, fmt.Errorf("write prompt file: %w", err)
+		}
+		promptFile = f.Name()
+	}
+
+	args := make([]string, len(b.args))
+	for i, arg := range b.args {
+		arg = strings.ReplaceAll(arg, "{prompt_file}", promptFile)
+		args[i] = strings.ReplaceAll(arg, "{prompt}", req.Prompt)
+	}
+
+	log.Printf("[EXECUTIONER] Running %s", b.args[0])
+	res, err := b.exec.run(ctx, b.args[0], nil, args[0], args[1:]...)
+	summary.Command = res
+	summary.Duration = res.Duration
+	summary.Result = res.Output()
+	summary.Turns = 1
+	summary.IsError = err != nil
+	return summary, err
+}
@@ -0,0 +1,227 @@
+package agents
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheConfig configures the on-disk response cache.
+type CacheConfig struct {
+	Dir      string
+	TTL      time.Duration // entries older than this are ignored; 0 keeps them forever
+	MaxBytes int64         // total cache size before the least recently used entries are evicted; 0 is unbounded
+
+	// AllowNonZeroTemperature also caches sampled (temperature > 0) requests.
+	AllowNonZeroTemperature bool
+}
+
+// ResponseCache stores chat responses on disk, keyed by the provider, model,
+// temperature and a hash of the conversation.
+type ResponseCache struct {
+	cfg    CacheConfig
+	mu     sync.Mutex // serializes writes and eviction
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type cacheEntry struct {
+	CreatedAt time.Time     `json:"created_at"`
+	Response  *ChatResponse `json:"response"`
+}
+
+func NewResponseCache(cfg CacheConfig) (*ResponseCache, error) {
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+	return &ResponseCache{cfg: cfg}, nil
+}
+
+// Stats returns the number of cache hits and misses so far.
+func (c *ResponseCache) Stats() (hits, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// cacheable reports whether a request may be served from the cache.
+func (c *ResponseCache) cacheable(req *ChatRequest) bool {
+	return req.Temperature == 0 || c.cfg.AllowNonZeroTemperature
+}
+
+func (c *ResponseCache) key(provider, model string, req *ChatRequest) string {
+	h := sha256.New()
+	json.NewEncoder(h).Encode(struct {
+		Provider    string
+		Model       string
+		Temperature float64
+		MaxTokens   int
+		Messages    []ChatMessage
+		Tools       []ToolSpec
+	}{provider, model, req.Temperature, req.MaxTokens, req.Messages, req.Tools})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.cfg.Dir, key[:2], key+".json")
+}
+
+func (c *ResponseCache) get(key string) (*ChatResponse, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Response == nil {
+		os.Remove(c.path(key))
+		return nil, false
+	}
+
+	if c.cfg.TTL > 0 && time.Since(entry.CreatedAt) > c.cfg.TTL {
+		os.Remove(c.path(key))
+		return nil, false
+	}
+
+	// The mtime marks the last use, so eviction keeps hot entries.
+	now := time.Now()
+	os.Chtimes(c.path(key), now, now)
+	return entry.Response, true
+}
+
+func (c *ResponseCache) put(key string, resp *ChatResponse) error {
+	data, err := json.Marshal(cacheEntry{CreatedAt: time.Now(), Response: resp})
+	if err != nil {
+		return fmt.Errorf("marshal cache entry: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create cache dir: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write cache entry: %w", err)
+	}
+
+	return c.evictLocked()
+}
+
+// evictLocked removes the least recently used entries until the cache fits
+// MaxBytes.
+func (c *ResponseCache) evictLocked() error {
+	if c.cfg.MaxBytes <= 0 {
+		return nil
+	}
+
+	type file struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var files []file
+	var total int64
+	err := filepath.WalkDir(c.cfg.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, file{path, info.Size(), info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("scan cache: %w", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= c.cfg.MaxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err == nil {
+			total -= f.size
+		}
+	}
+
+	return nil
+}
+
+// cachedProvider serves repeated requests from a ResponseCache.
+type cachedProvider struct {
+	Provider
+	model string
+	cache *ResponseCache
+}
+
+// NewCachedProvider puts cache in front of p. model is the model p is
+// configured with and becomes part of the cache key.
+func NewCachedProvider(p Provider, model string, cache *ResponseCache) Provider {
+	return &cachedProvider{Provider: p, model: model, cache: cache}
+}
+
+func (p *cachedProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	return p.lookup(req, func() (*ChatResponse, error) {
+		return p.Provider.Chat(ctx, req)
+	})
+}
+
+// ChatStream streams misses when the wrapped provider supports it; hits are
+// delivered as a single chunk.
+func (p *cachedProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	hit := true
+	resp, err := p.lookup(req, func() (*ChatResponse, error) {
+		hit = false
+		if sp, ok := p.Provider.(StreamingProvider); ok {
+			return sp.ChatStream(ctx, req, onDelta)
+		}
+		return p.Provider.Chat(ctx, req)
+	})
+	if err == nil && hit {
+		onDelta(resp.Content())
+	}
+	return resp, err
+}
+
+func (p *cachedProvider) lookup(req *ChatRequest, call func() (*ChatResponse, error)) (*ChatResponse, error) {
+	if !p.cache.cacheable(req) {
+		return call()
+	}
+
+	key := p.cache.key(p.Name(), orDefault(req.Model, p.model), req)
+	if resp, ok := p.cache.get(key); ok {
+		hits := p.cache.hits.Add(1)
+		log.Printf("[CACHE] hit %s (%s) — %d hits, %d misses", key[:12], p.Name(), hits, p.cache.misses.Load())
+		// Cached calls cost nothing.
+		cached := *resp
+		cached.Usage = Usage{}
+		return &cached, nil
+	}
+
+	misses := p.cache.misses.Add(1)
+	log.Printf("[CACHE] miss %s (%s) — %d hits, %d misses", key[:12], p.Name(), p.cache.hits.Load(), misses)
+
+	resp, err := call()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.cache.put(key, resp); err != nil {
+		log.Printf("[CACHE] store failed: %v", err)
+	}
+	return resp, nil
+}
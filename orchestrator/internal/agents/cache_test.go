@@ -0,0 +1,153 @@
+package agents
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheKey(t *testing.T) {
+	c := &ResponseCache{}
+	base := ChatRequest{
+		Messages:  []ChatMessage{{Role: "user", Content: "fix the build"}},
+		MaxTokens: 100,
+	}
+	baseKey := c.key("openai", "deepseek-chat", &base)
+
+	tests := []struct {
+		name     string
+		provider string
+		model    string
+		change   func(r *ChatRequest)
+		same     bool
+	}{
+		{"identical", "openai", "deepseek-chat", func(r *ChatRequest) {}, true},
+		{"stream flag ignored", "openai", "deepseek-chat", func(r *ChatRequest) { r.Stream = true }, true},
+		{"provider", "anthropic", "deepseek-chat", func(r *ChatRequest) {}, false},
+		{"model", "openai", "deepseek-reasoner", func(r *ChatRequest) {}, false},
+		{"temperature", "openai", "deepseek-chat", func(r *ChatRequest) { r.Temperature = 0.7 }, false},
+		{"max tokens", "openai", "deepseek-chat", func(r *ChatRequest) { r.MaxTokens = 200 }, false},
+		{"message", "openai", "deepseek-chat", func(r *ChatRequest) { r.Messages[0].Content = "fix the tests" }, false},
+		{"role", "openai", "deepseek-chat", func(r *ChatRequest) { r.Messages[0].Role = "system" }, false},
+		{"tools", "openai", "deepseek-chat", func(r *ChatRequest) {
+			r.Tools = []ToolSpec{{Type: "function", Function: ToolFunction{Name: "grep"}}}
+		}, false},
+	}
+	for _, tt := range tests {
+		req := base
+		req.Messages = append([]ChatMessage(nil), base.Messages...)
+		tt.change(&req)
+		if got := c.key(tt.provider, tt.model, &req) == baseKey; got != tt.same {
+			t.Errorf("%s: same key = %t, want %t", tt.name, got, tt.same)
+		}
+	}
+}
+
+func newTestCache(t *testing.T, cfg CacheConfig) *ResponseCache {
+	t.Helper()
+	cfg.Dir = t.TempDir()
+	c, err := NewResponseCache(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCachedProvider(t *testing.T) {
+	fake := NewFakeProvider("first", "second", "third")
+	p := NewCachedProvider(fake, "m", newTestCache(t, CacheConfig{}))
+	ask := func(content string, temperature float64) string {
+		resp, err := p.Chat(context.Background(), &ChatRequest{
+			Messages:    []ChatMessage{{Role: "user", Content: content}},
+			Temperature: temperature,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Content()
+	}
+
+	if got := ask("a", 0); got != "first" {
+		t.Fatalf("miss = %q", got)
+	}
+	if got := ask("a", 0); got != "first" {
+		t.Errorf("hit = %q, want the cached reply", got)
+	}
+	if got := ask("a", 0.5); got != "second" {
+		t.Errorf("sampled request = %q, want it sent to the provider", got)
+	}
+	if got := ask("b", 0); got != "third" {
+		t.Errorf("other conversation = %q", got)
+	}
+	if n := len(fake.Requests()); n != 3 {
+		t.Errorf("provider got %d requests, want 3", n)
+	}
+
+	var streamed string
+	resp, err := p.(StreamingProvider).ChatStream(context.Background(), &ChatRequest{
+		Messages: []ChatMessage{{Role: "user", Content: "a"}},
+	}, func(s string) { streamed += s })
+	if err != nil || streamed != "first" || resp.Usage != (Usage{}) {
+		t.Errorf("streamed hit = %q, usage %+v, err %v", streamed, resp.Usage, err)
+	}
+}
+
+func TestResponseCacheTTL(t *testing.T) {
+	c := newTestCache(t, CacheConfig{TTL: time.Hour})
+	resp := &ChatResponse{Choices: []ChatChoice{{Message: ChatMessage{Content: "x"}}}}
+	if err := c.put("aa01", resp); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.get("aa01"); !ok {
+		t.Fatal("fresh entry missed")
+	}
+
+	c.cfg.TTL = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if _, ok := c.get("aa01"); ok {
+		t.Error("expired entry served")
+	}
+	if _, err := os.Stat(c.path("aa01")); !os.IsNotExist(err) {
+		t.Errorf("expired entry not removed: %v", err)
+	}
+}
+
+func TestResponseCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newTestCache(t, CacheConfig{})
+	resp := &ChatResponse{Choices: []ChatChoice{{Message: ChatMessage{Content: "reply"}}}}
+	keys := []string{"aa01", "bb02", "cc03"}
+	for i, key := range keys {
+		if err := c.put(key, resp); err != nil {
+			t.Fatal(err)
+		}
+		// Distinct, ordered mtimes regardless of file system resolution.
+		at := time.Now().Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(c.path(key), at, at)
+	}
+	info, err := os.Stat(c.path("aa01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Using the oldest entry makes bb02 the least recently used.
+	if _, ok := c.get("aa01"); !ok {
+		t.Fatal("aa01 missed")
+	}
+	// Room for three entries, whose sizes differ by a few bytes.
+	c.cfg.MaxBytes = 3*info.Size() + info.Size()/2
+	if err := c.put("dd04", resp); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		key  string
+		kept bool
+	}{{"aa01", true}, {"bb02", false}, {"cc03", true}, {"dd04", true}} {
+		_, err := os.Stat(filepath.Join(c.cfg.Dir, tt.key[:2], tt.key+".json"))
+		if kept := err == nil; kept != tt.kept {
+			t.Errorf("%s kept = %t, want %t", tt.key, kept, tt.kept)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package agents
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ExecEventKind is the kind of a Claude CLI stream-json event.
+type ExecEventKind string
+
+const (
+	ExecEventInit       ExecEventKind = "init"
+	ExecEventText       ExecEventKind = "text"
+	ExecEventToolUse    ExecEventKind = "tool_use"
+	ExecEventFileEdit   ExecEventKind = "file_edit"
+	ExecEventToolResult ExecEventKind = "tool_result"
+	ExecEventResult     ExecEventKind = "result"
+)
+
+// ExecEvent is one typed event from `claude --output-format stream-json`.
+// Fields that do not apply to the kind are left zero.
+type ExecEvent struct {
+	Kind      ExecEventKind
+	SessionID string
+	Model     string          // init
+	Text      string          // text, result, and tool_result output
+	Tool      string          // tool_use, file_edit, tool_result
+	ToolID    string          // tool_use, tool_result
+	Input     json.RawMessage // tool_use
+	File      string          // file_edit
+	IsError   bool            // tool_result, result
+	Turns     int             // result
+	CostUSD   float64         // result
+	Duration  time.Duration   // result
+	Usage     Usage           // result
+}
+
+// Stream-json wire types.
+type claudeStreamLine struct {
+	Type         string         `json:"type"`
+	Subtype      string         `json:"subtype"`
+	SessionID    string         `json:"session_id"`
+	Model        string         `json:"model"`
+	Message      *claudeMessage `json:"message"`
+	Result       string         `json:"result"`
+	IsError      bool           `json:"is_error"`
+	NumTurns     int            `json:"num_turns"`
+	TotalCostUSD float64        `json:"total_cost_usd"`
+	DurationMS   int64          `json:"duration_ms"`
+	Usage        claudeUsage    `json:"usage"`
+}
+
+type claudeUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+type claudeMessage struct {
+	Content []claudeBlock `json:"content"`
+}
+
+type claudeBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"`
+	IsError   bool            `json:"is_error"`
+}
+
+// fileEditTools are the CLI tools that modify files, with their path field.
+var fileEditTools = map[string]string{
+	"Edit":         "file_path",
+	"MultiEdit":    "file_path",
+	"Write":        "file_path",
+	"NotebookEdit": "notebook_path",
+}
+
+// claudeStream turns stream-json lines into events and accumulates the
+// summary. It is fed one line at a time as the CLI writes them.
+type claudeStream struct {
+	summary ExecutionSummary
+	done    bool              // a result event was seen
+	tools   map[string]string // tool_use id → tool name
+	onEvent func(ExecEvent)
+}
+
+func newClaudeStream(onEvent func(ExecEvent)) *claudeStream {
+	return &claudeStream{
+		summary: ExecutionSummary{ToolUses: make(map[string]int)},
+		tools:   make(map[string]string),
+		onEvent: onEvent,
+	}
+}
+
+func (s *claudeStream) emit(ev ExecEvent) {
+	if s.onEvent != nil {
+		s.onEvent(ev)
+	}
+}
+
+// Line handles one line of output; lines that aren't JSON events are ignored.
+func (s *claudeStream) Line(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return
+	}
+	var ev claudeStreamLine
+	if err := json.Unmarshal(line, &ev); err != nil {
+		return
+	}
+	if ev.SessionID != "" {
+		s.summary.SessionID = ev.SessionID
+	}
+
+	switch ev.Type {
+	case "system":
+		if ev.Subtype == "init" {
+			s.emit(ExecEvent{Kind: ExecEventInit, SessionID: ev.SessionID, Model: ev.Model})
+		}
+
+	case "assistant":
+		if ev.Message == nil {
+			return
+		}
+		for _, block := range ev.Message.Content {
+			switch block.Type {
+			case "text":
+				s.emit(ExecEvent{Kind: ExecEventText, SessionID: ev.SessionID, Text: block.Text})
+			case "tool_use":
+				s.toolUse(ev.SessionID, block)
+			}
+		}
+
+	case "user":
+		if ev.Message == nil {
+			return
+		}
+		for _, block := range ev.Message.Content {
+			if block.Type != "tool_result" {
+				continue
+			}
+			if block.IsError {
+				s.summary.ToolErrors++
+			}
+			s.emit(ExecEvent{
+				Kind:      ExecEventToolResult,
+				SessionID: ev.SessionID,
+				Tool:      s.tools[block.ToolUseID],
+				ToolID:    block.ToolUseID,
+				Text:      toolResultText(block.Content),
+				IsError:   block.IsError,
+			})
+		}
+
+	case "result":
+		usage := Usage{
+			PromptTokens:     ev.Usage.InputTokens + ev.Usage.CacheCreationInputTokens + ev.Usage.CacheReadInputTokens,
+			CompletionTokens: ev.Usage.OutputTokens,
+		}
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+		s.done = true
+		s.summary.Result = ev.Result
+		s.summary.Turns = ev.NumTurns
+		s.summary.CostUSD = ev.TotalCostUSD
+		s.summary.Usage = usage
+		s.summary.IsError = ev.IsError || ev.Subtype != "success"
+		s.emit(ExecEvent{
+			Kind:      ExecEventResult,
+			SessionID: ev.SessionID,
+			Text:      ev.Result,
+			IsError:   s.summary.IsError,
+			Turns:     ev.NumTurns,
+			CostUSD:   ev.TotalCostUSD,
+			Duration:  time.Duration(ev.DurationMS) * time.Millisecond,
+			Usage:     usage,
+		})
+	}
+}
+
+func (s *claudeStream) toolUse(sessionID string, block claudeBlock) {
+	s.tools[block.ID] = block.Name
+	s.summary.ToolUses[block.Name]++
+	s.emit(ExecEvent{Kind: ExecEventToolUse, SessionID: sessionID, Tool: block.Name, ToolID: block.ID, Input: block.Input})
+
+	var input map[string]any
+	json.Unmarshal(block.Input, &input)
+	if block.Name == "Bash" {
+		if command, ok := input["command"].(string); ok {
+			s.summary.Commands = append(s.summary.Commands, command)
+		}
+	}
+	if field, ok := fileEditTools[block.Name]; ok {
+		if path, ok := input[field].(string); ok {
+			if !slices.Contains(s.summary.FilesEdited, path) {
+				s.summary.FilesEdited = append(s.summary.FilesEdited, path)
+			}
+			s.emit(ExecEvent{Kind: ExecEventFileEdit, SessionID: sessionID, Tool: block.Name, File: path})
+		}
+	}
+}
+
+// toolResultText flattens a tool_result's content, which is either a string
+// or a list of text blocks.
+func toolResultText(raw json.RawMessage) string {
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	var blocks []claudeBlock
+	json.Unmarshal(raw, &blocks)
+	parts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		if b.Text != "" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// lineWriter calls fn for each complete line written to it.
+type lineWriter struct {
+	fn  func([]byte)
+	buf []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.fn(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush passes on a final unterminated line.
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.fn(w.buf)
+		w.buf = nil
+	}
+}
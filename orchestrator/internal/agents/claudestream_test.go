@@ -0,0 +1,106 @@
+package agents
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const claudeSession = `{"type":"system","subtype":"init","session_id":"s1","model":"claude-sonnet-4-5","tools":["Bash","Edit"]}
+{"type":"assistant","session_id":"s1","message":{"content":[{"type":"text","text":"Looking at the test."},{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go test ./..."}}]}}
+{"type":"user","session_id":"s1","message":{"content":[{"type":"tool_result","tool_use_id":"t1","content":"FAIL","is_error":true}]}}
+{"type":"assistant","session_id":"s1","message":{"content":[{"type":"tool_use","id":"t2","name":"Edit","input":{"file_path":"main.go","old_string":"a","new_string":"b"}}]}}
+{"type":"user","session_id":"s1","message":{"content":[{"type":"tool_result","tool_use_id":"t2","content":[{"type":"text","text":"edited"},{"type":"text","text":"main.go"}]}]}}
+warning: not a JSON line
+{"type":"assistant","session_id":"s1","message":{"content":[{"type":"tool_use","id":"t3","name":"Write","input":{"file_path":"main.go","content":"package main"}}]}}
+{"type":"result","subtype":"success","session_id":"s1","result":"Fixed it.","num_turns":4,"total_cost_usd":0.12,"duration_ms":1500,"usage":{"input_tokens":100,"output_tokens":20,"cache_creation_input_tokens":5,"cache_read_input_tokens":50}}
+`
+
+func TestClaudeStreamSession(t *testing.T) {
+	var events []ExecEvent
+	s := newClaudeStream(func(ev ExecEvent) { events = append(events, ev) })
+	w := &lineWriter{fn: s.Line}
+	// Feed in odd-sized chunks, as the CLI's pipe would.
+	for data := claudeSession; data != ""; {
+		n := min(len(data), 37)
+		w.Write([]byte(data[:n]))
+		data = data[n:]
+	}
+	w.Flush()
+
+	usage := Usage{PromptTokens: 155, CompletionTokens: 20, TotalTokens: 175}
+	want := ExecutionSummary{
+		SessionID:   "s1",
+		Result:      "Fixed it.",
+		FilesEdited: []string{"main.go"},
+		ToolUses:    map[string]int{"Bash": 1, "Edit": 1, "Write": 1},
+		Commands:    []string{"go test ./..."},
+		ToolErrors:  1,
+		Turns:       4,
+		CostUSD:     0.12,
+		Usage:       usage,
+	}
+	if !s.done {
+		t.Error("result event not recorded")
+	}
+	if !reflect.DeepEqual(s.summary, want) {
+		t.Errorf("summary:\n got %+v\nwant %+v", s.summary, want)
+	}
+
+	var kinds []ExecEventKind
+	for _, ev := range events {
+		kinds = append(kinds, ev.Kind)
+	}
+	wantKinds := []ExecEventKind{
+		ExecEventInit, ExecEventText, ExecEventToolUse, ExecEventToolResult,
+		ExecEventToolUse, ExecEventFileEdit, ExecEventToolResult,
+		ExecEventToolUse, ExecEventFileEdit, ExecEventResult,
+	}
+	if !reflect.DeepEqual(kinds, wantKinds) {
+		t.Fatalf("event kinds:\n got %v\nwant %v", kinds, wantKinds)
+	}
+
+	if ev := events[0]; ev.Model != "claude-sonnet-4-5" || ev.SessionID != "s1" {
+		t.Errorf("init event = %+v", ev)
+	}
+	if ev := events[3]; ev.Tool != "Bash" || ev.Text != "FAIL" || !ev.IsError {
+		t.Errorf("string tool_result = %+v", ev)
+	}
+	if ev := events[6]; ev.Tool != "Edit" || ev.Text != "edited\nmain.go" || ev.IsError {
+		t.Errorf("block tool_result = %+v", ev)
+	}
+	if ev := events[9]; ev.Turns != 4 || ev.Duration != 1500*time.Millisecond || ev.Usage != usage || ev.IsError {
+		t.Errorf("result event = %+v", ev)
+	}
+}
+
+func TestClaudeStreamResultIsError(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{`{"type":"result","subtype":"success","is_error":false}`, false},
+		{`{"type":"result","subtype":"success","is_error":true}`, true},
+		{`{"type":"result","subtype":"error_max_turns","is_error":false}`, true},
+		{`{"type":"result","subtype":"error_during_execution"}`, true},
+	}
+
+	for _, tt := range tests {
+		s := newClaudeStream(nil)
+		s.Line([]byte(tt.line))
+		if s.summary.IsError != tt.want {
+			t.Errorf("%s: IsError = %v, want %v", tt.line, s.summary.IsError, tt.want)
+		}
+	}
+}
+
+func TestClaudeStreamIgnoresNoise(t *testing.T) {
+	s := newClaudeStream(func(ev ExecEvent) { t.Errorf("unexpected event %+v", ev) })
+	for _, line := range strings.Split("\n  \nnot json\n{broken\n{\"type\":\"assistant\"}\n{\"type\":\"user\"}", "\n") {
+		s.Line([]byte(line))
+	}
+	if s.done || s.summary.SessionID != "" {
+		t.Errorf("summary = %+v", s.summary)
+	}
+}
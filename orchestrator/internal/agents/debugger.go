@@ -1,29 +1,33 @@
 package agents
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"time"
+	"log"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/prompts"
 )
 
 // Debugger analyzes error logs and produces fixes.
 type Debugger struct {
-	apiKey string
-	apiURL string
-	model  string
-	client *http.Client
+	persona
+	toolRoot      string
+	maxToolRounds int
+	repairTurns   int
 }
 
-func NewDebugger(apiKey, apiURL, model string) *Debugger {
+func NewDebugger(provider Provider) *Debugger {
 	return &Debugger{
-		apiKey: apiKey,
-		apiURL: apiURL,
-		model:  model,
-		client: &http.Client{Timeout: 120 * time.Second},
+		persona: persona{
+			name:         "Debugger",
+			provider:     provider,
+			systemPrompt: prompts.SystemWith(prompts.DebuggerSystem, prompts.Data{AllowedCommands: DefaultCommandPolicy().Allowed()}),
+			temperature:  0.0,
+			maxTokens:    2048,
+			retry:        DefaultRetryPolicy(),
+		},
+		repairTurns: 2,
 	}
 }
 
@@ -31,81 +35,132 @@ func (d *Debugger) Name() string {
 	return "Debugger"
 }
 
-func (d *Debugger) Execute(ctx context.Context, prompt string) (string, error) {
-	messages := []ChatMessage{
-		{
-			Role: "system",
-			Content: `You are an expert debugger. Analyze the error log and source code provided.
-Output a JSON object with exactly these fields:
-{
-  "analysis": "Brief description of the root cause",
-  "fix_type": "code_patch" | "command" | "config_change",
-  "fix_content": "The exact fix to apply (code diff, command to run, or config to change)"
+// EnableTools lets the Debugger inspect the project at root through the
+// read_file and grep tools and submit its answer through propose_fix.
+func (d *Debugger) EnableTools(root string, maxRounds int) {
+	d.toolRoot = root
+	d.maxToolRounds = maxRounds
 }
-Only output valid JSON. No additional text.`,
-		},
-		{
-			Role:    "user",
-			Content: prompt,
-		},
-	}
 
-	return d.call(ctx, messages)
+func (d *Debugger) Execute(ctx context.Context, prompt string) (string, error) {
+	return d.execute(ctx, d.messages(prompt))
 }
 
-// ParseDebugResult parses the debugger's JSON output into a DebugResult.
-func (d *Debugger) ParseDebugResult(output string) (*DebugResult, error) {
-	var result DebugResult
-	if err := json.Unmarshal([]byte(output), &result); err != nil {
-		return nil, fmt.Errorf("failed to parse debug result: %w\nraw output: %s", err, output)
+func (d *Debugger) execute(ctx context.Context, messages []ChatMessage) (string, error) {
+	if d.toolRoot != "" {
+		return d.executeWithTools(ctx, messages)
 	}
-	return &result, nil
+	return d.chat(ctx, messages)
 }
 
-func (d *Debugger) call(ctx context.Context, messages []ChatMessage) (string, error) {
-	reqBody := ChatRequest{
-		Model:       d.model,
-		Messages:    messages,
-		Temperature: 0.0,
-		MaxTokens:   2048,
+// executeWithTools runs the tool loop and returns the proposed fix as JSON.
+// If the model answers without calling propose_fix its text is returned.
+func (d *Debugger) executeWithTools(ctx context.Context, messages []ChatMessage) (string, error) {
+	if err := d.checkBudget(); err != nil {
+		return "", err
 	}
 
-	body, err := json.Marshal(reqBody)
+	messages = append([]ChatMessage(nil), messages...)
+	messages[0].Content += "\n\nUse the read_file and grep tools to inspect the project before answering. " +
+		"Submit your answer by calling propose_fix instead of writing JSON."
+
+	var result DebugResult
+	resp, _, err := RunTools(ctx, d.retrying(), &ChatRequest{
+		Messages:    messages,
+		Temperature: d.temperature,
+		MaxTokens:   d.maxTokens,
+	}, debuggerTools(d.toolRoot, &result), d.maxToolRounds)
 	if err != nil {
-		return "", fmt.Errorf("marshal request: %w", err)
+		// Turns that completed before the failure were still paid for.
+		if resp != nil {
+			if budgetErr := d.recordUsage(resp); budgetErr != nil {
+				return "", budgetErr
+			}
+		}
+		return "", fmt.Errorf("%s: %w", d.provider.Name(), err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.apiURL, bytes.NewReader(body))
-	if err != nil {
-		return "", fmt.Errorf("create request: %w", err)
+	if err := d.recordUsage(resp); err != nil {
+		return "", err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+d.apiKey)
+	if result.FixType == "" {
+		return resp.Content(), nil
+	}
 
-	resp, err := d.client.Do(req)
+	out, err := json.Marshal(result)
 	if err != nil {
-		return "", fmt.Errorf("API call failed: %w", err)
+		return "", fmt.Errorf("marshal debug result: %w", err)
 	}
-	defer resp.Body.Close()
+	return string(out), nil
+}
+
+// SetRepairTurns sets how many times Diagnose asks the model to correct
+// output that fails validation.
+func (d *Debugger) SetRepairTurns(n int) {
+	d.repairTurns = n
+}
+
+// Diagnose asks for a fix and returns it only once it has been validated
+// against DebugResultSchema. Invalid output is sent back to the model with
+// the reason it was rejected, up to the configured number of repair turns.
+// Previous attempts in memory, if any, are replayed before the prompt.
+func (d *Debugger) Diagnose(ctx context.Context, prompt string, memory *DebugMemory) (*DebugResult, error) {
+	messages := append([]ChatMessage{{Role: "system", Content: d.systemPrompt}}, memory.conversation(prompt)...)
 
-	respBody, err := io.ReadAll(resp.Body)
+	raw, err := d.execute(ctx, messages)
 	if err != nil {
-		return "", fmt.Errorf("read response: %w", err)
+		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API returned %d: %s", resp.StatusCode, string(respBody))
+	for turn := 0; ; turn++ {
+		result, perr := parseDebugResult(raw)
+		if perr == nil {
+			return result, nil
+		}
+		if turn >= d.repairTurns {
+			return nil, fmt.Errorf("debugger output invalid after %d repair turns: %w", turn, perr)
+		}
+
+		log.Printf("[DEBUGGER] Invalid output (%v), requesting repair %d/%d", perr, turn+1, d.repairTurns)
+		messages = append(messages,
+			ChatMessage{Role: "assistant", Content: raw},
+			ChatMessage{Role: "user", Content: fmt.Sprintf(
+				"Your output was invalid because %v. Reply with only a JSON object with the fields analysis, fix_type (code_patch, command or config_change) and fix_content. No markdown fences, no other text.",
+				perr)},
+		)
+
+		raw, err = d.chat(ctx, messages)
+		if err != nil {
+			return nil, err
+		}
 	}
+}
 
-	var chatResp ChatResponse
-	if err := json.Unmarshal(respBody, &chatResp); err != nil {
-		return "", fmt.Errorf("unmarshal response: %w", err)
+// ParseDebugResult parses the debugger's JSON output into a DebugResult.
+func (d *Debugger) ParseDebugResult(output string) (*DebugResult, error) {
+	result, err := parseDebugResult(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse debug result: %w\nraw output: %s", err, output)
+	}
+	return result, nil
+}
+
+// parseDebugResult extracts the JSON object from output, strips any markdown
+// fences, and validates it against DebugResultSchema.
+func parseDebugResult(output string) (*DebugResult, error) {
+	doc, err := ExtractJSON(output)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("no choices in API response")
+	if err := DebugResultSchema.Validate([]byte(doc)); err != nil {
+		return nil, err
 	}
 
-	return chatResp.Choices[0].Message.Content, nil
+	var result DebugResult
+	if err := json.Unmarshal([]byte(doc), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
 }
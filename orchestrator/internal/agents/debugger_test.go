@@ -0,0 +1,71 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDebuggerToolLoopUsageRecordedOnFailure(t *testing.T) {
+	provider := NewFakeProvider()
+	for i := 0; i < 3; i++ {
+		provider.PushMessage(ChatMessage{
+			Role:      "assistant",
+			ToolCalls: []ToolCall{{ID: "call", Type: "function", Function: ToolCallFunction{Name: "grep", Arguments: `{"pattern":"x"}`}}},
+		})
+	}
+
+	ledger := NewLedger(PriceTable{ProviderFake: {InputPerMTok: 1e6}}, 0, 0)
+	debugger := NewDebugger(provider)
+	debugger.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+	debugger.SetLedger(ledger)
+	debugger.EnableTools(t.TempDir(), 3)
+
+	_, err := debugger.Diagnose(context.Background(), "tests fail", nil)
+	if !errors.Is(err, ErrMaxToolIterations) {
+		t.Fatalf("err = %v, want ErrMaxToolIterations", err)
+	}
+
+	totals := ledger.TaskTotals()
+	if totals.PromptTokens == 0 || totals.CostUSD == 0 {
+		t.Errorf("tool turns were not recorded: %+v", totals)
+	}
+}
+
+func TestDebuggerToolLoopStopsOnBudget(t *testing.T) {
+	provider := NewFakeProvider()
+	for i := 0; i < 2; i++ {
+		provider.PushMessage(ChatMessage{
+			Role:      "assistant",
+			ToolCalls: []ToolCall{{ID: "call", Type: "function", Function: ToolCallFunction{Name: "grep", Arguments: `{"pattern":"x"}`}}},
+		})
+	}
+
+	ledger := NewLedger(PriceTable{ProviderFake: {InputPerMTok: 1e6}}, 0.01, 0)
+	debugger := NewDebugger(provider)
+	debugger.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+	debugger.SetLedger(ledger)
+	debugger.EnableTools(t.TempDir(), 2)
+
+	_, err := debugger.Diagnose(context.Background(), "tests fail", nil)
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("err = %v, want a budget error", err)
+	}
+}
+
+func TestLedgerRecordCost(t *testing.T) {
+	ledger := NewLedger(nil, 1, 0)
+	ledger.StartTask("t1")
+	if err := ledger.RecordCost("Executioner", BackendClaude, Usage{PromptTokens: 10}, 0.4); err != nil {
+		t.Fatal(err)
+	}
+	err := ledger.RecordCost("Executioner", BackendClaude, Usage{PromptTokens: 10}, 0.7)
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) || budgetErr.Scope != BudgetScopeTask {
+		t.Fatalf("err = %v, want a task budget error", err)
+	}
+	if totals := ledger.TaskTotals(); totals.Calls != 2 || totals.CostUSD != 1.1 {
+		t.Errorf("totals = %+v", totals)
+	}
+}
@@ -0,0 +1,196 @@
+package agents
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/patch"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/retrieval"
+)
+
+const (
+	maxReadFileBytes = 64 * 1024
+	maxGrepResults   = 50
+)
+
+// debuggerTools returns the tools the Debugger can use against the project
+// tree. A successful propose_fix call stores its arguments in result.
+func debuggerTools(root string, result *DebugResult) []Tool {
+	return []Tool{
+		{
+			Name:        "read_file",
+			Description: "Read a file from the project. Paths are relative to the project root. Optionally restrict to a 1-based inclusive line range.",
+			Parameters: json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "path": {"type": "string"},
+    "start_line": {"type": "integer"},
+    "end_line": {"type": "integer"}
+  },
+  "required": ["path"]
+}`),
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				var args struct {
+					Path      string `json:"path"`
+					StartLine int    `json:"start_line"`
+					EndLine   int    `json:"end_line"`
+				}
+				if err := json.Unmarshal(raw, &args); err != nil {
+					return "", fmt.Errorf("invalid arguments: %w", err)
+				}
+				return readFileTool(root, args.Path, args.StartLine, args.EndLine)
+			},
+		},
+		{
+			Name:        "grep",
+			Description: "Search project files for a regular expression. Returns matching lines as path:line: text.",
+			Parameters: json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "pattern": {"type": "string"},
+    "path": {"type": "string", "description": "Sub-directory to search, relative to the project root"}
+  },
+  "required": ["pattern"]
+}`),
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				var args struct {
+					Pattern string `json:"pattern"`
+					Path    string `json:"path"`
+				}
+				if err := json.Unmarshal(raw, &args); err != nil {
+					return "", fmt.Errorf("invalid arguments: %w", err)
+				}
+				return grepTool(ctx, root, args.Pattern, args.Path)
+			},
+		},
+		{
+			Name:        "propose_fix",
+			Description: "Submit the final diagnosis and fix. Call this exactly once when you are done investigating.",
+			Parameters:  json.RawMessage(debugResultSchema),
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				if err := DebugResultSchema.Validate(raw); err != nil {
+					return "", fmt.Errorf("invalid fix: %w", err)
+				}
+				var fix DebugResult
+				if err := json.Unmarshal(raw, &fix); err != nil {
+					return "", fmt.Errorf("invalid arguments: %w", err)
+				}
+				*result = fix
+				return "fix recorded", ErrToolsDone
+			},
+		},
+	}
+}
+
+func readFileTool(root, rel string, start, end int) (string, error) {
+	path, err := patch.ResolveInRoot(root, rel)
+	if err != nil {
+		return "", err
+	}
+	if retrieval.IsSecret(filepath.Base(path)) {
+		return "", fmt.Errorf("%s may hold secrets and cannot be read", rel)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", rel, err)
+	}
+
+	if start <= 0 && end <= 0 {
+		if len(data) > maxReadFileBytes {
+			return string(data[:maxReadFileBytes]) + "\n... (truncated, request a line range)", nil
+		}
+		return string(data), nil
+	}
+
+	lines := strings.Split(string(data), "\n")
+	if start <= 0 {
+		start = 1
+	}
+	if end <= 0 || end > len(lines) {
+		end = len(lines)
+	}
+	if start > end {
+		return "", fmt.Errorf("invalid line range %d-%d (file has %d lines)", start, end, len(lines))
+	}
+
+	var b strings.Builder
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&b, "%d: %s\n", i, lines[i-1])
+	}
+	return b.String(), nil
+}
+
+func grepTool(ctx context.Context, root, pattern, sub string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	dir := root
+	if sub != "" && filepath.Clean(sub) != "." {
+		if dir, err = patch.ResolveInRoot(root, sub); err != nil {
+			return "", err
+		}
+	}
+
+	var matches []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			if retrieval.SkipDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if retrieval.IsSecret(d.Name()) {
+			return nil
+		}
+		if len(matches) >= maxGrepResults {
+			return filepath.SkipAll
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+
+		rel, _ := filepath.Rel(dir, path)
+		rel = filepath.Join(sub, rel)
+		scanner := bufio.NewScanner(f)
+		for n := 1; scanner.Scan(); n++ {
+			line := scanner.Text()
+			if strings.IndexByte(line, 0) >= 0 {
+				return nil // binary file
+			}
+			if re.MatchString(line) {
+				matches = append(matches, fmt.Sprintf("%s:%d: %s", filepath.ToSlash(rel), n, strings.TrimSpace(line)))
+				if len(matches) >= maxGrepResults {
+					break
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(matches) == 0 {
+		return "no matches", nil
+	}
+	return strings.Join(matches, "\n"), nil
+}
@@ -0,0 +1,85 @@
+package agents
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDebugToolsHideSecrets(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":        "package main // API_KEY is read from the environment\n",
+		".env":           "API_KEY=hunter2\n",
+		".env.example":   "API_KEY=\n",
+		"tls/server.key": "API_KEY private key\n",
+		"deploy/id_rsa":  "API_KEY private key\n",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, rel := range []string{".env", "tls/server.key", "./.env", "deploy/id_rsa"} {
+		if out, err := readFileTool(root, rel, 0, 0); err == nil {
+			t.Errorf("read_file %s = %q, want an error", rel, out)
+		}
+	}
+	if _, err := readFileTool(root, ".env.example", 0, 0); err != nil {
+		t.Errorf("read_file .env.example: %v", err)
+	}
+
+	out, err := grepTool(context.Background(), root, "API_KEY", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "hunter2") || strings.Contains(out, "server.key") || strings.Contains(out, "id_rsa") {
+		t.Errorf("grep exposed a secret file:\n%s", out)
+	}
+	if !strings.Contains(out, "main.go:1:") || !strings.Contains(out, ".env.example:1:") {
+		t.Errorf("grep missed regular files:\n%s", out)
+	}
+}
+
+func TestDebugToolsStayInRoot(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("TOKEN\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"pkg", "node_modules/dep"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, dir, "a.go"), []byte("// TOKEN\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, rel := range []string{"../secret.txt", "link/secret.txt", filepath.Join(outside, "secret.txt")} {
+		if _, err := readFileTool(root, rel, 0, 0); err == nil {
+			t.Errorf("read_file %s: want an error", rel)
+		}
+	}
+	if _, err := grepTool(context.Background(), root, "TOKEN", "link"); err == nil {
+		t.Error("grep below a symlink out of the root: want an error")
+	}
+
+	for _, sub := range []string{"", ".", "pkg"} {
+		out, err := grepTool(context.Background(), root, "TOKEN", sub)
+		if err != nil {
+			t.Fatalf("grep in %q: %v", sub, err)
+		}
+		if out != "pkg/a.go:1: // TOKEN" {
+			t.Errorf("grep in %q = %q", sub, out)
+		}
+	}
+}
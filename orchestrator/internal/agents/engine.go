@@ -1,30 +1,26 @@
 package agents
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
-	"io"
-	"math"
-	"net/http"
-	"time"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/prompts"
 )
 
 // Engine is the code generation agent (DeepSeek-V3 / GLM-4).
 type Engine struct {
-	apiKey  string
-	apiURL  string
-	model   string
-	client  *http.Client
+	persona
 }
 
-func NewEngine(apiKey, apiURL, model string) *Engine {
+func NewEngine(provider Provider) *Engine {
 	return &Engine{
-		apiKey: apiKey,
-		apiURL: apiURL,
-		model:  model,
-		client: &http.Client{Timeout: 120 * time.Second},
+		persona: persona{
+			name:         "Engine",
+			provider:     provider,
+			systemPrompt: prompts.System(prompts.EngineSystem),
+			temperature:  0.1,
+			maxTokens:    4096,
+			retry:        DefaultRetryPolicy(),
+		},
 	}
 }
 
@@ -33,87 +29,5 @@ func (e *Engine) Name() string {
 }
 
 func (e *Engine) Execute(ctx context.Context, prompt string) (string, error) {
-	messages := []ChatMessage{
-		{
-			Role:    "system",
-			Content: "You are an expert full-stack engineer. Generate clean, production-ready code. Follow best practices for Go, TypeScript, and React Native. Output only code and necessary explanations. No markdown fences unless showing file contents.",
-		},
-		{
-			Role:    "user",
-			Content: prompt,
-		},
-	}
-
-	return e.callWithRetry(ctx, messages, 3)
-}
-
-func (e *Engine) callWithRetry(ctx context.Context, messages []ChatMessage, maxRetries int) (string, error) {
-	var lastErr error
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			backoff := time.Duration(math.Pow(2, float64(attempt))) * time.Second
-			select {
-			case <-ctx.Done():
-				return "", ctx.Err()
-			case <-time.After(backoff):
-			}
-		}
-
-		result, err := e.call(ctx, messages)
-		if err == nil {
-			return result, nil
-		}
-		lastErr = err
-	}
-
-	return "", fmt.Errorf("engine failed after %d retries: %w", maxRetries, lastErr)
-}
-
-func (e *Engine) call(ctx context.Context, messages []ChatMessage) (string, error) {
-	reqBody := ChatRequest{
-		Model:       e.model,
-		Messages:    messages,
-		Temperature: 0.1,
-		MaxTokens:   4096,
-	}
-
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("marshal request: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.apiURL, bytes.NewReader(body))
-	if err != nil {
-		return "", fmt.Errorf("create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+e.apiKey)
-
-	resp, err := e.client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("API call failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API returned %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	var chatResp ChatResponse
-	if err := json.Unmarshal(respBody, &chatResp); err != nil {
-		return "", fmt.Errorf("unmarshal response: %w", err)
-	}
-
-	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("no choices in API response")
-	}
-
-	return chatResp.Choices[0].Message.Content, nil
+	return e.chat(ctx, e.messages(prompt))
 }
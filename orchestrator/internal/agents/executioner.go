@@ -1,78 +1,221 @@
 package agents
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os/exec"
 	"strings"
 	"time"
 )
 
-// Executioner runs Claude CLI commands for file creation and terminal operations.
+const (
+	// DefaultMaxOutputBytes caps how much of each output stream is captured.
+	DefaultMaxOutputBytes = 1 << 20
+	// DefaultKillGrace is how long a cancelled command's process group has
+	// between SIGTERM and SIGKILL.
+	DefaultKillGrace = 10 * time.Second
+)
+
+// CommandResult is the outcome of a spawned command.
+type CommandResult struct {
+	Command   string
+	ExitCode  int // -1 when the process did not exit normally
+	Stdout    string
+	Stderr    string
+	Duration  time.Duration
+	TimedOut  bool
+	Truncated bool // an output stream exceeded the limit; its middle was dropped
+}
+
+// Success reports whether the command exited with code 0.
+func (r *CommandResult) Success() bool {
+	return r.ExitCode == 0 && !r.TimedOut
+}
+
+// Output combines stdout and stderr, noting a timeout or truncation.
+func (r *CommandResult) Output() string {
+	output := strings.TrimSpace(r.Stdout)
+	if errOutput := strings.TrimSpace(r.Stderr); errOutput != "" {
+		if output != "" {
+			output += "\n"
+		}
+		output += "--- stderr ---\n" + errOutput
+	}
+	if r.TimedOut {
+		output += fmt.Sprintf("\n--- timed out after %s ---", r.Duration.Round(time.Second))
+	}
+	if r.Truncated {
+		output += "\n--- output truncated ---"
+	}
+	return output
+}
+
+// CommandError is returned when a command fails or times out. The full
+// output is on Result rather than in the message.
+type CommandError struct {
+	Result *CommandResult
+}
+
+func (e *CommandError) Error() string {
+	r := e.Result
+	msg := fmt.Sprintf("%s exited with code %d", commandName(r.Command), r.ExitCode)
+	if r.TimedOut {
+		msg = fmt.Sprintf("%s timed out after %s", commandName(r.Command), r.Duration.Round(time.Second))
+	}
+	if last := lastLine(r.Stderr); last != "" {
+		msg += ": " + last
+	}
+	return msg
+}
+
+func commandName(command string) string {
+	if len(command) > 60 {
+		return command[:60] + "..."
+	}
+	return command
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	return s[strings.LastIndexByte(s, '\n')+1:]
+}
+
+// Executioner runs the loop's commands: tests, command fixes and the CLI
+// execution backends.
 type Executioner struct {
-	timeout time.Duration
-	workDir string
+	timeout        time.Duration
+	workDir        string
+	killGrace      time.Duration
+	maxOutputBytes int
 }
 
 func NewExecutioner(workDir string) *Executioner {
 	return &Executioner{
-		timeout: 600 * time.Second,
-		workDir: workDir,
+		timeout:        600 * time.Second,
+		workDir:        workDir,
+		killGrace:      DefaultKillGrace,
+		maxOutputBytes: DefaultMaxOutputBytes,
 	}
 }
 
+// SetKillGrace sets how long cancelled commands get to exit after SIGTERM.
+func (e *Executioner) SetKillGrace(d time.Duration) {
+	e.killGrace = d
+}
+
+// SetMaxOutputBytes caps the captured size of each output stream; the head
+// and tail are kept.
+func (e *Executioner) SetMaxOutputBytes(n int) {
+	e.maxOutputBytes = n
+}
+
 func (e *Executioner) Name() string {
 	return "Executioner"
 }
 
-func (e *Executioner) Execute(ctx context.Context, prompt string) (string, error) {
-	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+// RunShellCommand executes an arbitrary shell command. The result is
+// returned even when the command fails, so its output can be inspected.
+func (e *Executioner) RunShellCommand(ctx context.Context, command string) (*CommandResult, error) {
+	return e.run(ctx, command, nil, "sh", "-c", command)
+}
+
+// run executes name with args in the work directory, also copying stdout to
+// tee if set. It returns a *CommandError for non-zero exits and timeouts, and
+// the context's error if the caller cancelled.
+func (e *Executioner) run(parent context.Context, display string, tee io.Writer, name string, args ...string) (*CommandResult, error) {
+	ctx, cancel := context.WithTimeout(parent, e.timeout)
 	defer cancel()
 
-	// Use claude CLI in print mode with full tool access
-	cmd := exec.CommandContext(ctx, "claude", "-p", "--dangerously-skip-permissions", prompt)
+	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Dir = e.workDir
+	release := setProcessGroup(cmd, e.killGrace)
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	stdout := newHeadTailBuffer(e.maxOutputBytes)
+	stderr := newHeadTailBuffer(e.maxOutputBytes)
+	cmd.Stdout = stdout
+	if tee != nil {
+		cmd.Stdout = io.MultiWriter(stdout, tee)
+	}
+	cmd.Stderr = stderr
 
+	start := time.Now()
 	err := cmd.Run()
-	output := stdout.String()
-	errOutput := stderr.String()
-
-	if err != nil {
-		return "", fmt.Errorf("claude CLI error: %w\nstdout: %s\nstderr: %s",
-			err, output, errOutput)
+	release()
+	result := &CommandResult{
+		Command:   display,
+		ExitCode:  -1,
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Duration:  time.Since(start),
+		Truncated: stdout.dropped > 0 || stderr.dropped > 0,
 	}
-
-	if errOutput != "" {
-		output += "\n--- stderr ---\n" + errOutput
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
 	}
 
-	return strings.TrimSpace(output), nil
+	switch {
+	case parent.Err() != nil:
+		return result, parent.Err()
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.TimedOut = true
+		return result, &CommandError{Result: result}
+	case err != nil && cmd.ProcessState == nil:
+		return result, fmt.Errorf("start %s: %w", name, err)
+	case err != nil:
+		return result, &CommandError{Result: result}
+	}
+	return result, nil
 }
 
-// RunShellCommand executes an arbitrary shell command and returns its output.
-func (e *Executioner) RunShellCommand(ctx context.Context, command string) (string, error) {
-	ctx, cancel := context.WithTimeout(ctx, e.timeout)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, "sh", "-c", command)
-	cmd.Dir = e.workDir
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// headTailBuffer keeps the first and last limit/2 bytes written, the tail in
+// a ring, and counts what was dropped in between.
+type headTailBuffer struct {
+	head    []byte
+	tail    []byte // ring of capacity limit/2 once the head is full
+	next    int    // write position in tail
+	full    bool   // tail has wrapped
+	half    int
+	dropped int64
+}
 
-	err := cmd.Run()
-	output := stdout.String()
+func newHeadTailBuffer(limit int) *headTailBuffer {
+	half := max(limit/2, 1)
+	return &headTailBuffer{half: half, head: make([]byte, 0, half)}
+}
 
-	if err != nil {
-		return "", fmt.Errorf("command failed: %w\nstdout: %s\nstderr: %s",
-			err, output, stderr.String())
+func (b *headTailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := b.half - len(b.head); room > 0 {
+		take := min(room, len(p))
+		b.head = append(b.head, p[:take]...)
+		p = p[take:]
+	}
+	for len(p) > 0 {
+		if b.tail == nil {
+			b.tail = make([]byte, b.half)
+		}
+		if b.full {
+			b.dropped += int64(min(len(p), b.half-b.next))
+		}
+		copied := copy(b.tail[b.next:], p)
+		p = p[copied:]
+		b.next += copied
+		if b.next == b.half {
+			b.next, b.full = 0, true
+		}
 	}
+	return n, nil
+}
 
-	return strings.TrimSpace(output), nil
+func (b *headTailBuffer) String() string {
+	tail := string(b.tail[:b.next])
+	if b.full {
+		tail = string(b.tail[b.next:]) + tail
+	}
+	if b.dropped == 0 {
+		return string(b.head) + tail
+	}
+	return fmt.Sprintf("%s\n... [%d bytes omitted] ...\n%s", b.head, b.dropped, tail)
 }
@@ -0,0 +1,54 @@
+package agents
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHeadTailBuffer(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		writes  []string
+		want    string
+		dropped int64
+	}{
+		{"under limit", 10, []string{"abc", "de"}, "abcde", 0},
+		{"exactly full", 6, []string{"abcdef"}, "abcdef", 0},
+		{"one write over", 6, []string{"abcdefgh"}, "abc\n... [2 bytes omitted] ...\nfgh", 2},
+		{"many small writes", 6, []string{"ab", "cd", "ef", "gh", "ij"}, "abc\n... [4 bytes omitted] ...\nhij", 4},
+		{"write larger than the ring", 4, []string{"ab", "cdefghij"}, "ab\n... [6 bytes omitted] ...\nij", 6},
+		{"odd limit", 5, []string{"0123456789"}, "01\n... [6 bytes omitted] ...\n89", 6},
+		{"empty", 8, nil, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newHeadTailBuffer(tt.limit)
+			for _, w := range tt.writes {
+				if n, err := b.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			if b.dropped != tt.dropped {
+				t.Errorf("dropped = %d, want %d", b.dropped, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestRunShellCommandTruncatesOutput(t *testing.T) {
+	e := NewExecutioner(t.TempDir())
+	e.SetMaxOutputBytes(100)
+
+	res, err := e.RunShellCommand(t.Context(), "seq 1 1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Truncated || !strings.HasPrefix(res.Stdout, "1\n2\n") || !strings.HasSuffix(res.Stdout, "999\n1000\n") {
+		t.Errorf("stdout = %q, truncated %t", res.Stdout, res.Truncated)
+	}
+}
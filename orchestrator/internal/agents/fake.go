@@ -0,0 +1,98 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+func init() {
+	RegisterProvider(ProviderFake, func(cfg ProviderConfig) (Provider, error) {
+		return NewFakeProvider(), nil
+	})
+}
+
+// FakeProvider returns scripted responses without any network access.
+// When the script runs out it echoes the last user message back.
+type FakeProvider struct {
+	mu        sync.Mutex
+	responses []ChatMessage
+	requests  []ChatRequest
+}
+
+func NewFakeProvider(responses ...string) *FakeProvider {
+	f := &FakeProvider{}
+	f.Push(responses...)
+	return f
+}
+
+func (f *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+// Push appends responses to the script.
+func (f *FakeProvider) Push(responses ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range responses {
+		f.responses = append(f.responses, ChatMessage{Role: "assistant", Content: r})
+	}
+}
+
+// PushMessage appends a full assistant message, e.g. one with tool calls.
+func (f *FakeProvider) PushMessage(msg ChatMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, msg)
+}
+
+// Requests returns every request the provider has received.
+func (f *FakeProvider) Requests() []ChatRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]ChatRequest(nil), f.requests...)
+}
+
+func (f *FakeProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, *req)
+
+	msg := ChatMessage{Role: "assistant"}
+	if len(f.responses) > 0 {
+		msg = f.responses[0]
+		f.responses = f.responses[1:]
+	} else {
+		msg.Content = fmt.Sprintf("fake response to: %s", lastUserMessage(req.Messages))
+	}
+
+	promptTokens := 0
+	for _, m := range req.Messages {
+		promptTokens += len(m.Content) / 4
+	}
+	completionTokens := len(msg.Content) / 4
+
+	return &ChatResponse{
+		Model:   orDefault(req.Model, ProviderFake),
+		Choices: []ChatChoice{{Message: msg}},
+		Usage: Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		},
+	}, nil
+}
+
+func lastUserMessage(messages []ChatMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
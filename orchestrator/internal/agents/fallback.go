@@ -0,0 +1,216 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// CircuitBreaker stops calls to a provider after consecutive failures. Once
+// the cooldown has passed a single probe is let through; if it succeeds the
+// breaker closes again, otherwise it re-opens.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: max(threshold, 1),
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// Allow reports whether a call may be made now.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		return true
+	case BreakerHalfOpen:
+		return false // a probe is already in flight
+	default:
+		return true
+	}
+}
+
+// Success records a successful call and closes the breaker.
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = BreakerClosed
+	b.failures = 0
+}
+
+// Failure records a failed call and opens the breaker once the threshold is
+// reached or a half-open probe fails.
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Abort records a call that ended without a verdict, such as one the caller
+// cancelled. A half-open probe goes back to open with its cooldown already
+// over, so the next call probes again.
+func (b *CircuitBreaker) Abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerOpen
+	}
+}
+
+// State returns the breaker's current state.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// FallbackEntry is one provider in a fallback chain. Label identifies it in
+// logs and errors, e.g. "openai:deepseek-chat".
+type FallbackEntry struct {
+	Label    string
+	Provider Provider
+}
+
+// ProviderAttempt records what happened to one entry of a fallback chain.
+type ProviderAttempt struct {
+	Label   string
+	Err     error
+	Skipped bool // circuit breaker was open
+}
+
+// FallbackError is returned when every provider in a chain failed.
+type FallbackError struct {
+	Attempts []ProviderAttempt
+}
+
+func (e *FallbackError) Error() string {
+	parts := make([]string, len(e.Attempts))
+	for i, a := range e.Attempts {
+		if a.Skipped {
+			parts[i] = a.Label + ": circuit open"
+		} else {
+			parts[i] = fmt.Sprintf("%s: %v", a.Label, a.Err)
+		}
+	}
+	return "all providers failed (attempted " + strings.Join(parts, "; ") + ")"
+}
+
+// Unwrap exposes the individual provider errors to errors.Is and errors.As.
+// RetryPolicy classifies the chain as a whole rather than by the first match.
+func (e *FallbackError) Unwrap() []error {
+	var errs []error
+	for _, a := range e.Attempts {
+		if a.Err != nil {
+			errs = append(errs, a.Err)
+		}
+	}
+	return errs
+}
+
+// FallbackProvider tries an ordered list of providers, skipping those whose
+// circuit breaker is open.
+type FallbackProvider struct {
+	entries  []FallbackEntry
+	breakers []*CircuitBreaker
+}
+
+// NewFallbackProvider builds a chain from entries in priority order. Each
+// entry gets a breaker that opens after threshold consecutive failures and
+// probes again after cooldown.
+func NewFallbackProvider(entries []FallbackEntry, threshold int, cooldown time.Duration) *FallbackProvider {
+	p := &FallbackProvider{entries: entries}
+	for range entries {
+		p.breakers = append(p.breakers, NewCircuitBreaker(threshold, cooldown))
+	}
+	return p
+}
+
+func (p *FallbackProvider) Name() string {
+	labels := make([]string, len(p.entries))
+	for i, e := range p.entries {
+		labels[i] = e.Label
+	}
+	return "fallback(" + strings.Join(labels, ",") + ")"
+}
+
+func (p *FallbackProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	return p.try(ctx, func(provider Provider) (*ChatResponse, error) {
+		r := *req
+		return provider.Chat(ctx, &r)
+	})
+}
+
+func (p *FallbackProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	return p.try(ctx, func(provider Provider) (*ChatResponse, error) {
+		r := *req
+		if sp, ok := provider.(StreamingProvider); ok {
+			return sp.ChatStream(ctx, &r, onDelta)
+		}
+		return provider.Chat(ctx, &r)
+	})
+}
+
+func (p *FallbackProvider) try(ctx context.Context, call func(Provider) (*ChatResponse, error)) (*ChatResponse, error) {
+	var attempts []ProviderAttempt
+
+	for i, entry := range p.entries {
+		breaker := p.breakers[i]
+		if !breaker.Allow() {
+			attempts = append(attempts, ProviderAttempt{Label: entry.Label, Skipped: true})
+			continue
+		}
+
+		resp, err := call(entry.Provider)
+		if err == nil {
+			breaker.Success()
+			if i > 0 {
+				log.Printf("[FALLBACK] served by %s", entry.Label)
+			}
+			return resp, nil
+		}
+
+		// Only the caller giving up stops the chain; a context cancelled
+		// inside the provider, like a stream idle timeout, is a failure.
+		if ctx.Err() != nil {
+			breaker.Abort()
+			return nil, err
+		}
+
+		breaker.Failure()
+		attempts = append(attempts, ProviderAttempt{Label: entry.Label, Err: err})
+		log.Printf("[FALLBACK] %s failed (breaker %s): %v", entry.Label, breaker.State(), err)
+	}
+
+	return nil, &FallbackError{Attempts: attempts}
+}
@@ -0,0 +1,167 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stallingProvider fails like a stream cut by its idle timer: with an error
+// wrapping context.Canceled while the caller's context is still live.
+type stallingProvider struct {
+	calls int
+}
+
+func (p *stallingProvider) Name() string { return "stalling" }
+
+func (p *stallingProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	p.calls++
+	streamCtx, idle, stop := newIdleTimer(ctx, time.Millisecond)
+	defer stop()
+	<-streamCtx.Done()
+	return nil, idle.Err(streamCtx.Err())
+}
+
+type cancellingProvider struct {
+	cancel context.CancelFunc
+}
+
+func (p *cancellingProvider) Name() string { return "cancelling" }
+
+func (p *cancellingProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	p.cancel()
+	return nil, ctx.Err()
+}
+
+func TestFallbackIdleTimeoutTriesNextProvider(t *testing.T) {
+	stalled := &stallingProvider{}
+	chain := NewFallbackProvider([]FallbackEntry{
+		{Label: "primary", Provider: stalled},
+		{Label: "secondary", Provider: NewFakeProvider("ok")},
+	}, 1, time.Millisecond)
+
+	resp, err := chain.Chat(context.Background(), &ChatRequest{Messages: []ChatMessage{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.Content() != "ok" {
+		t.Errorf("content = %q, want the secondary's reply", resp.Content())
+	}
+	if chain.breakers[0].State() != BreakerOpen {
+		t.Errorf("primary breaker = %s, want open", chain.breakers[0].State())
+	}
+
+	// After the cooldown the stalled probe must re-open the breaker, not
+	// leave it half-open forever.
+	time.Sleep(5 * time.Millisecond)
+	if _, err := chain.Chat(context.Background(), &ChatRequest{}); err != nil {
+		t.Fatalf("Chat after cooldown: %v", err)
+	}
+	if stalled.calls != 2 {
+		t.Errorf("primary called %d times, want 2", stalled.calls)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if !chain.breakers[0].Allow() {
+		t.Errorf("primary breaker stuck in %s", chain.breakers[0].State())
+	}
+}
+
+func TestFallbackCallerCancelStopsChain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	second := NewFakeProvider("unused")
+	chain := NewFallbackProvider([]FallbackEntry{
+		{Label: "primary", Provider: &cancellingProvider{cancel: cancel}},
+		{Label: "secondary", Provider: second},
+	}, 1, time.Millisecond)
+
+	// Open the primary breaker and let the cooldown pass, so the next call
+	// is a half-open probe.
+	chain.breakers[0].Failure()
+	time.Sleep(5 * time.Millisecond)
+
+	_, err := chain.Chat(ctx, &ChatRequest{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if len(second.Requests()) != 0 {
+		t.Error("secondary was called after the caller cancelled")
+	}
+	if !chain.breakers[0].Allow() {
+		t.Errorf("cancelled probe left the breaker %s", chain.breakers[0].State())
+	}
+}
+
+func TestFallbackErrorListsAttempts(t *testing.T) {
+	chain := NewFallbackProvider([]FallbackEntry{
+		{Label: "a", Provider: &stallingProvider{}},
+		{Label: "b", Provider: &stallingProvider{}},
+	}, 3, time.Minute)
+
+	_, err := chain.Chat(context.Background(), &ChatRequest{})
+	var fe *FallbackError
+	if !errors.As(err, &fe) || len(fe.Attempts) != 2 {
+		t.Fatalf("err = %v, want a FallbackError with 2 attempts", err)
+	}
+	if !strings.Contains(err.Error(), "a: stream idle") {
+		t.Errorf("error = %q", err)
+	}
+}
+
+// scriptedErrProvider returns its errors in turn, then succeeds.
+type scriptedErrProvider struct {
+	errs  []error
+	calls int
+}
+
+func (p *scriptedErrProvider) Name() string { return "scripted" }
+
+func (p *scriptedErrProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	p.calls++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return nil, err
+	}
+	return &ChatResponse{Choices: []ChatChoice{{Message: ChatMessage{Role: "assistant", Content: "ok"}}}}, nil
+}
+
+func TestRetryFallbackChainMixedErrors(t *testing.T) {
+	unauthorized := &APIError{StatusCode: 401, Body: "bad key"}
+	unavailable := &APIError{StatusCode: 503, Body: "overloaded"}
+	tests := []struct {
+		name      string
+		primary   []error
+		secondary []error
+		ok        bool
+		calls     int // calls of the secondary
+	}{
+		{"401 then 503 retried", []error{unauthorized, unauthorized}, []error{unavailable}, true, 2},
+		{"503 then 401 retried", []error{unavailable}, []error{unauthorized}, true, 1},
+		{"all 401 not retried", []error{unauthorized, unauthorized}, []error{unauthorized, unauthorized}, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &scriptedErrProvider{errs: tt.primary}
+			secondary := &scriptedErrProvider{errs: tt.secondary}
+			chain := NewFallbackProvider([]FallbackEntry{
+				{Label: "primary", Provider: primary},
+				{Label: "secondary", Provider: secondary},
+			}, 5, time.Minute)
+			p := &retryProvider{Provider: chain, name: "test", policy: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+
+			resp, err := p.Chat(context.Background(), &ChatRequest{})
+			if (err == nil) != tt.ok {
+				t.Fatalf("err = %v, want ok=%v", err, tt.ok)
+			}
+			if tt.ok && resp.Content() != "ok" {
+				t.Errorf("content = %q", resp.Content())
+			}
+			if secondary.calls != tt.calls {
+				t.Errorf("secondary called %d times, want %d", secondary.calls, tt.calls)
+			}
+		})
+	}
+}
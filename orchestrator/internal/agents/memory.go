@@ -0,0 +1,153 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/ctxbudget"
+)
+
+// memoryOutputTokens caps the test output kept per remembered attempt.
+const memoryOutputTokens = 1500
+
+// DebugTurn is one past correction attempt.
+type DebugTurn struct {
+	Attempt    int
+	TestOutput string // failure output the fix was proposed for
+	Fix        *DebugResult
+	Outcome    string // what happened when the fix was applied
+}
+
+// DebugMemory is the Debugger's per-task history of analyses, applied fixes
+// and their results. Only the most recent turns are replayed in full; older
+// ones are reduced to a one-line summary.
+type DebugMemory struct {
+	window    int
+	maxTokens int
+	turns     []*DebugTurn
+}
+
+func NewDebugMemory(window int) *DebugMemory {
+	return &DebugMemory{window: max(window, 1)}
+}
+
+// SetMaxTokens caps the replayed history; 0 is unlimited. Over the cap the
+// oldest full turns are summarized first, then the remaining turns' output
+// and fixes are shortened.
+func (m *DebugMemory) SetMaxTokens(n int) {
+	m.maxTokens = n
+}
+
+// Tokens estimates how many tokens the history adds in front of a prompt.
+func (m *DebugMemory) Tokens() int {
+	if m == nil || len(m.turns) == 0 {
+		return 0
+	}
+	return messageTokens(m.history())
+}
+
+// Len returns the number of recorded attempts.
+func (m *DebugMemory) Len() int {
+	return len(m.turns)
+}
+
+// AddFix records a fix proposed for testOutput.
+func (m *DebugMemory) AddFix(attempt int, testOutput string, fix *DebugResult) {
+	m.turns = append(m.turns, &DebugTurn{
+		Attempt:    attempt,
+		TestOutput: ctxbudget.HeadTail(testOutput, memoryOutputTokens),
+		Fix:        fix,
+	})
+}
+
+// SetOutcome records how applying the latest fix went.
+func (m *DebugMemory) SetOutcome(outcome string) {
+	if len(m.turns) > 0 {
+		m.turns[len(m.turns)-1].Outcome = outcome
+	}
+}
+
+// conversation returns the remembered turns as alternating user/assistant
+// messages followed by prompt. The resulting test output of each fix is the
+// input of the next turn, so it is not repeated.
+func (m *DebugMemory) conversation(prompt string) []ChatMessage {
+	if m == nil || len(m.turns) == 0 {
+		return []ChatMessage{{Role: "user", Content: prompt}}
+	}
+	messages := m.history()
+	messages[len(messages)-1].Content += prompt
+	return messages
+}
+
+// history renders the turns within maxTokens, ending with a user message
+// the prompt is appended to.
+func (m *DebugMemory) history() []ChatMessage {
+	start := max(len(m.turns)-m.window, 0)
+	messages := m.render(start, 0)
+	if m.maxTokens <= 0 {
+		return messages
+	}
+	for start < len(m.turns)-1 && messageTokens(messages) > m.maxTokens {
+		start++
+		messages = m.render(start, 0)
+	}
+	for _, limit := range []int{1000, 400, 150, 50} {
+		if messageTokens(messages) <= m.maxTokens {
+			break
+		}
+		messages = m.render(start, limit)
+	}
+	return messages
+}
+
+// render replays turns from start in full and summarizes the ones before.
+// A non-zero limit shortens each turn's output, fix and outcome to that many
+// tokens.
+func (m *DebugMemory) render(start, limit int) []ChatMessage {
+	shorten := func(s string) string {
+		if limit > 0 {
+			return ctxbudget.HeadTail(s, limit)
+		}
+		return s
+	}
+
+	var messages []ChatMessage
+	var carry strings.Builder
+
+	if start > 0 {
+		carry.WriteString("Earlier attempts that did not fix the problem:\n")
+		for _, t := range m.turns[:start] {
+			fmt.Fprintf(&carry, "- attempt %d (%s): %s\n", t.Attempt, t.Fix.FixType, ctxbudget.Truncate(t.Fix.Analysis, 60))
+		}
+		carry.WriteString("\n")
+	}
+
+	for _, t := range m.turns[start:] {
+		fmt.Fprintf(&carry, "Attempt %d, test output:\n%s", t.Attempt, shorten(t.TestOutput))
+		messages = append(messages, ChatMessage{Role: "user", Content: carry.String()})
+		carry.Reset()
+
+		fix := *t.Fix
+		fix.Analysis, fix.FixContent = shorten(fix.Analysis), shorten(fix.FixContent)
+		data, _ := json.Marshal(fix)
+		messages = append(messages, ChatMessage{Role: "assistant", Content: string(data)})
+
+		outcome := t.Outcome
+		if outcome == "" {
+			outcome = "applied"
+		}
+		fmt.Fprintf(&carry, "Result of your fix from attempt %d: %s. The tests still fail.\n\n", t.Attempt, shorten(outcome))
+	}
+
+	carry.WriteString("Do not repeat a fix that has already failed.\n\n")
+	return append(messages, ChatMessage{Role: "user", Content: carry.String()})
+}
+
+func messageTokens(messages []ChatMessage) int {
+	n := 0
+	for _, msg := range messages {
+		n += ctxbudget.EstimateTokens(msg.Content)
+	}
+	return n
+}
@@ -0,0 +1,61 @@
+package agents
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func fillMemory(m *DebugMemory, turns int, outputLines int) {
+	for i := 1; i <= turns; i++ {
+		var out strings.Builder
+		for l := 0; l < outputLines; l++ {
+			fmt.Fprintf(&out, "main.go:%d:1: undefined: symbol%d\n", l+1, l)
+		}
+		m.AddFix(i, out.String(), &DebugResult{Analysis: fmt.Sprintf("analysis %d", i), FixType: FixTypeCodePatch, FixContent: strings.Repeat("+line\n", outputLines)})
+		m.SetOutcome(fmt.Sprintf("outcome %d", i))
+	}
+}
+
+func TestDebugMemoryConversation(t *testing.T) {
+	m := NewDebugMemory(2)
+	fillMemory(m, 3, 2)
+
+	messages := m.conversation("PROMPT")
+	// Two full turns (user, assistant each) and the final prompt.
+	if len(messages) != 5 {
+		t.Fatalf("got %d messages, want 5", len(messages))
+	}
+	if !strings.Contains(messages[0].Content, "- attempt 1 (code_patch): analysis 1") {
+		t.Errorf("first message does not summarize attempt 1:\n%s", messages[0].Content)
+	}
+	if !strings.Contains(messages[2].Content, "Result of your fix from attempt 2: outcome 2") {
+		t.Errorf("outcome of attempt 2 missing:\n%s", messages[2].Content)
+	}
+	last := messages[len(messages)-1].Content
+	if !strings.HasSuffix(last, "PROMPT") || !strings.Contains(last, "outcome 3") {
+		t.Errorf("last message = %q", last)
+	}
+
+	if got := (*DebugMemory)(nil).conversation("PROMPT"); len(got) != 1 || got[0].Content != "PROMPT" {
+		t.Errorf("nil memory conversation = %+v", got)
+	}
+}
+
+func TestDebugMemoryMaxTokens(t *testing.T) {
+	m := NewDebugMemory(3)
+	fillMemory(m, 4, 200)
+	full := m.Tokens()
+
+	m.SetMaxTokens(600)
+	if got := m.Tokens(); got > 600 || got >= full {
+		t.Errorf("Tokens() = %d with a 600 cap (uncapped %d)", got, full)
+	}
+	messages := m.conversation("PROMPT")
+	if got := messageTokens(messages[:len(messages)-1]); got > 600 {
+		t.Errorf("history is %d tokens, over the cap", got)
+	}
+	if last := messages[len(messages)-1].Content; !strings.Contains(last, "outcome 4") || !strings.HasSuffix(last, "PROMPT") {
+		t.Errorf("latest outcome or prompt lost: %q", last)
+	}
+}
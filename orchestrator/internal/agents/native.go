@@ -0,0 +1,117 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/ctxbudget"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/patch"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/retrieval"
+)
+
+const (
+	nativeMaxFiles     = 10
+	nativeMaxFileBytes = 64 * 1024
+)
+
+// NativeContextTokens caps the file contents the native backend adds to a
+// prompt; the prompt itself must leave room for them.
+const NativeContextTokens = 20000
+
+// NativeBackend applies edits the Engine writes as unified diffs or
+// whole-file blocks, with no external coding agent. The engine should be
+// given the edit system prompt.
+type NativeBackend struct {
+	engine  *Engine
+	root    string
+	applier *patch.Applier
+}
+
+func NewNativeBackend(engine *Engine, root string) *NativeBackend {
+	return &NativeBackend{engine: engine, root: root, applier: patch.NewApplier(root)}
+}
+
+func (b *NativeBackend) Name() string {
+	return BackendNative
+}
+
+// Execute sends the prompt, with the current contents of the files it
+// mentions, to the Engine and applies the edits it returns.
+func (b *NativeBackend) Execute(ctx context.Context, req ExecRequest) (*ExecutionSummary, error) {
+	start := time.Now()
+	summary := &ExecutionSummary{ToolUses: make(map[string]int), Turns: 1}
+
+	reply, err := b.engine.Execute(ctx, req.Prompt+b.fileContext(req.Prompt))
+	summary.Duration = time.Since(start)
+	if err != nil {
+		summary.IsError = true
+		return summary, fmt.Errorf("native backend: %w", err)
+	}
+
+	edits, prose := patch.Parse(reply)
+	summary.Result = prose
+	if len(edits) == 0 {
+		summary.IsError = true
+		return summary, fmt.Errorf("native backend: the model returned no edits")
+	}
+
+	report := b.applier.Apply(edits)
+	log.Printf("[EXECUTIONER] Applied edits:\n%s", report)
+	applied, failed := report.Counts()
+	summary.ToolUses["Edit"] = applied + failed
+	summary.ToolErrors = failed
+	summary.FilesEdited = report.Written()
+	if !report.OK() {
+		summary.IsError = true
+		summary.Result += "\n\n" + report.String()
+		return summary, fmt.Errorf("native backend: %d of %d edits failed", failed, applied+failed)
+	}
+	return summary, nil
+}
+
+var pathRe = regexp.MustCompile(`[A-Za-z0-9_./-]+\.[A-Za-z0-9]+`)
+
+// fileContext renders the existing project files named in prompt as file
+// blocks, so the model edits their real contents.
+func (b *NativeBackend) fileContext(prompt string) string {
+	var out strings.Builder
+	seen := make(map[string]bool)
+	files := 0
+	for _, rel := range pathRe.FindAllString(prompt, -1) {
+		rel = strings.TrimPrefix(strings.TrimRight(rel, "."), "./")
+		if seen[rel] || files == nativeMaxFiles {
+			continue
+		}
+		seen[rel] = true
+
+		full, err := patch.ResolveInRoot(b.root, rel)
+		if err != nil || retrieval.IsSecret(filepath.Base(full)) {
+			continue
+		}
+		info, err := os.Stat(full)
+		if err != nil || !info.Mode().IsRegular() || info.Size() > nativeMaxFileBytes {
+			continue
+		}
+		data, err := os.ReadFile(full)
+		if err != nil {
+			continue
+		}
+
+		block := patch.FormatBlock(rel, string(data))
+		if ctxbudget.EstimateTokens(out.String()+block) > NativeContextTokens {
+			break
+		}
+		out.WriteString(block)
+		files++
+	}
+	if out.Len() == 0 {
+		return ""
+	}
+	return "\n\nCurrent contents of the files mentioned above:\n\n" + out.String()
+}
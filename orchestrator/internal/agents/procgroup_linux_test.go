@@ -0,0 +1,62 @@
+package agents
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// alive reports whether pid runs and is not a zombie waiting to be reaped.
+func alive(pid int) bool {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestRunKillsProcessGroupOnCancel(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+	}{
+		// The child exits on SIGTERM.
+		{"term", "sleep 30 & echo $!; wait"},
+		// The child ignores SIGTERM and must be killed.
+		{"kill", "(trap '' TERM; sleep 30; :) & echo $!; wait"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewExecutioner(t.TempDir())
+			e.SetKillGrace(200 * time.Millisecond)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			res, err := e.RunShellCommand(ctx, tt.command)
+			if err == nil {
+				t.Fatal("cancelled command succeeded")
+			}
+			if elapsed := time.Since(start); elapsed > 3*time.Second {
+				t.Errorf("run took %s after cancellation", elapsed)
+			}
+
+			pid, err := strconv.Atoi(strings.TrimSpace(res.Stdout))
+			if err != nil {
+				t.Fatalf("child pid: %q", res.Stdout)
+			}
+			deadline := time.Now().Add(2 * time.Second)
+			for alive(pid) && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if alive(pid) {
+				t.Errorf("child %d outlived the cancelled command", pid)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package agents
+
+import (
+	"os/exec"
+	"time"
+)
+
+// setProcessGroup falls back to killing only the direct child.
+func setProcessGroup(cmd *exec.Cmd, grace time.Duration) (release func()) {
+	cmd.WaitDelay = grace
+	return func() {}
+}
@@ -0,0 +1,56 @@
+//go:build unix
+
+package agents
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// setProcessGroup starts cmd in its own process group. On cancellation the
+// whole group gets SIGTERM and, if still alive after grace, SIGKILL, so
+// children such as go build or npx don't outlive the shell. The returned
+// release must be called once cmd.Wait returns: it stops the pending
+// SIGKILL, which could otherwise hit a new group that reused the ID, and
+// kills what is left of the group right away instead.
+func setProcessGroup(cmd *exec.Cmd, grace time.Duration) (release func()) {
+	var mu sync.Mutex
+	var kill *time.Timer
+	done := false
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		pgid := -cmd.Process.Pid
+		mu.Lock()
+		if !done {
+			kill = time.AfterFunc(grace, func() {
+				syscall.Kill(pgid, syscall.SIGKILL)
+			})
+		}
+		mu.Unlock()
+		if err := syscall.Kill(pgid, syscall.SIGTERM); err != nil {
+			if errors.Is(err, syscall.ESRCH) {
+				return os.ErrProcessDone
+			}
+			return err
+		}
+		return nil
+	}
+	// Stop waiting on pipes held open by processes that escaped the group.
+	cmd.WaitDelay = grace + time.Second
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		done = true
+		// Members still in the group keep its ID from being reused, so
+		// anything found there now is ours.
+		if kill != nil && kill.Stop() {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Provider sends chat requests to an LLM backend.
+type Provider interface {
+	Name() string
+	Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error)
+}
+
+// ModelChecker is implemented by providers that can verify at startup that
+// their configured model is available.
+type ModelChecker interface {
+	CheckModel(ctx context.Context) error
+}
+
+// ModelReporter is implemented by providers that know which model they
+// call, including the default they fall back to when none is configured.
+type ModelReporter interface {
+	Model() string
+}
+
+// ProviderConfig holds the connection settings a provider is created from.
+// Empty APIURL and Model fields fall back to the provider's defaults.
+type ProviderConfig struct {
+	APIKey      string
+	APIURL      string
+	Model       string
+	IdleTimeout time.Duration // max gap between stream chunks
+}
+
+// ProviderFactory creates a provider from its configuration.
+type ProviderFactory func(cfg ProviderConfig) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]ProviderFactory)
+)
+
+// RegisterProvider makes a provider available under the given name.
+// Provider implementations call it from init.
+func RegisterProvider(name string, factory ProviderFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("agents: provider %q registered twice", name))
+	}
+	registry[name] = factory
+}
+
+// NewProvider creates the provider registered under name.
+func NewProvider(name string, cfg ProviderConfig) (Provider, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q (available: %v)", name, Providers())
+	}
+
+	return factory(cfg)
+}
+
+// Providers returns the names of all registered providers.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func orDefault(val, fallback string) string {
+	if val != "" {
+		return val
+	}
+	return fallback
+}
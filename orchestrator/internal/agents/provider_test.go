@@ -0,0 +1,28 @@
+package agents
+
+import "testing"
+
+func TestProviderModelDefaults(t *testing.T) {
+	tests := []struct {
+		provider, model, want string
+	}{
+		{ProviderOpenAI, "", "deepseek-chat"},
+		{ProviderOpenAI, "gpt-4o", "gpt-4o"},
+		{ProviderAnthropic, "", "claude-sonnet-4-5"},
+		{ProviderOllama, "", "llama3.1"},
+		{ProviderOllama, "qwen2.5-coder", "qwen2.5-coder"},
+	}
+	for _, tt := range tests {
+		p, err := NewProvider(tt.provider, ProviderConfig{APIKey: "k", Model: tt.model})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.provider, err)
+		}
+		r, ok := p.(ModelReporter)
+		if !ok {
+			t.Fatalf("%s does not report its model", tt.provider)
+		}
+		if got := r.Model(); got != tt.want {
+			t.Errorf("%s with model %q: Model() = %q, want %q", tt.provider, tt.model, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package agents
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+)
+
+// RateLimit configures client-side throttling for one provider account.
+// Zero values are unlimited.
+type RateLimit struct {
+	RPM         int // requests per minute
+	TPM         int // tokens per minute
+	MaxInFlight int // concurrent requests
+}
+
+// tokenBucket refills continuously at capacity per minute. Its balance may go
+// negative when actual usage exceeds what was reserved, delaying later calls.
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(perMinute int) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		last:     time.Now(),
+	}
+}
+
+func (b *tokenBucket) refillLocked() {
+	now := time.Now()
+	b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Minutes()*b.capacity)
+	b.last = now
+}
+
+// wait blocks until n tokens are available and takes them. Requests larger
+// than the bucket only wait for a full bucket.
+func (b *tokenBucket) wait(ctx context.Context, n float64) error {
+	if b == nil {
+		return nil
+	}
+	n = min(n, b.capacity)
+
+	for {
+		b.mu.Lock()
+		b.refillLocked()
+		if b.tokens >= n {
+			b.tokens -= n
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((n - b.tokens) / b.capacity * float64(time.Minute))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// adjust corrects the balance once the real cost of a call is known.
+func (b *tokenBucket) adjust(delta float64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refillLocked()
+	b.tokens -= delta
+}
+
+// RateLimiter throttles calls with RPM/TPM token buckets and caps the number
+// of requests in flight.
+type RateLimiter struct {
+	requests *tokenBucket
+	tokens   *tokenBucket
+	slots    chan struct{}
+}
+
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	l := &RateLimiter{
+		requests: newTokenBucket(limit.RPM),
+		tokens:   newTokenBucket(limit.TPM),
+	}
+	if limit.MaxInFlight > 0 {
+		l.slots = make(chan struct{}, limit.MaxInFlight)
+	}
+	return l
+}
+
+// Acquire waits for an in-flight slot and rate budget for a request expected
+// to use estTokens. The returned function must be called with the tokens the
+// request actually used.
+func (l *RateLimiter) Acquire(ctx context.Context, estTokens int) (func(usedTokens int), error) {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	release := func(usedTokens int) {
+		if usedTokens > 0 {
+			l.tokens.adjust(float64(usedTokens - estTokens))
+		}
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+
+	if err := l.requests.wait(ctx, 1); err != nil {
+		release(0)
+		return nil, err
+	}
+	if err := l.tokens.wait(ctx, float64(estTokens)); err != nil {
+		release(0)
+		return nil, err
+	}
+
+	return release, nil
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*RateLimiter)
+)
+
+// SharedRateLimiter returns the limiter for a provider account, creating it
+// on first use. Every agent using the same provider, URL and API key shares
+// one limiter.
+func SharedRateLimiter(provider, apiURL, apiKey string, limit RateLimit) *RateLimiter {
+	sum := sha256.Sum256([]byte(apiKey))
+	key := provider + "|" + apiURL + "|" + hex.EncodeToString(sum[:8])
+
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	if l, ok := limiters[key]; ok {
+		return l
+	}
+	l := NewRateLimiter(limit)
+	limiters[key] = l
+	return l
+}
+
+// estimateTokens is a rough token count for a request's prompt.
+func estimateTokens(req *ChatRequest) int {
+	chars := 0
+	for _, m := range req.Messages {
+		chars += len(m.Content)
+		for _, call := range m.ToolCalls {
+			chars += len(call.Function.Arguments)
+		}
+	}
+	return chars/4 + 1
+}
+
+// rateLimitedProvider waits on a RateLimiter before every call.
+type rateLimitedProvider struct {
+	Provider
+	limiter *RateLimiter
+}
+
+// NewRateLimitedProvider throttles p with limiter.
+func NewRateLimitedProvider(p Provider, limiter *RateLimiter) Provider {
+	return &rateLimitedProvider{Provider: p, limiter: limiter}
+}
+
+func (p *rateLimitedProvider) acquire(ctx context.Context, req *ChatRequest) (func(int), error) {
+	start := time.Now()
+	release, err := p.limiter.Acquire(ctx, estimateTokens(req))
+	if err != nil {
+		return nil, err
+	}
+	if waited := time.Since(start); waited > time.Second {
+		log.Printf("[RATELIMIT] %s throttled for %s", p.Name(), waited.Round(time.Millisecond))
+	}
+	return release, nil
+}
+
+func (p *rateLimitedProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	release, err := p.acquire(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.Provider.Chat(ctx, req)
+	if err != nil {
+		release(0)
+		return nil, err
+	}
+	release(resp.Usage.TotalTokens)
+	return resp, nil
+}
+
+func (p *rateLimitedProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	sp, ok := p.Provider.(StreamingProvider)
+	if !ok {
+		return p.Chat(ctx, req)
+	}
+
+	release, err := p.acquire(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := sp.ChatStream(ctx, req, onDelta)
+	if err != nil {
+		release(0)
+		return nil, err
+	}
+	release(resp.Usage.TotalTokens)
+	return resp, nil
+}
@@ -0,0 +1,130 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	tests := []struct {
+		name      string
+		perMinute int
+		take      []float64 // taken without waiting
+		adjust    float64
+		next      float64
+		minWait   time.Duration
+		maxWait   time.Duration
+	}{
+		{"unlimited", 0, []float64{1e9}, 0, 1e9, 0, 10 * time.Millisecond},
+		{"within capacity", 6000, []float64{3000, 2999}, 0, 1, 0, 10 * time.Millisecond},
+		// 6000 per minute refills one token every 10ms.
+		{"empty waits for refill", 6000, []float64{6000}, 0, 5, 30 * time.Millisecond, 500 * time.Millisecond},
+		{"oversized waits for a full bucket only", 6000, nil, 0, 1e6, 0, 10 * time.Millisecond},
+		{"underestimate pushes the balance negative", 6000, []float64{6000}, 5, 1, 40 * time.Millisecond, 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTokenBucket(tt.perMinute)
+			for _, n := range tt.take {
+				if err := b.wait(context.Background(), n); err != nil {
+					t.Fatal(err)
+				}
+			}
+			b.adjust(tt.adjust)
+
+			start := time.Now()
+			if err := b.wait(context.Background(), tt.next); err != nil {
+				t.Fatal(err)
+			}
+			if waited := time.Since(start); waited < tt.minWait || waited > tt.maxWait {
+				t.Errorf("waited %s, want %s-%s", waited, tt.minWait, tt.maxWait)
+			}
+		})
+	}
+}
+
+func TestTokenBucketCancel(t *testing.T) {
+	b := newTokenBucket(1)
+	b.wait(context.Background(), 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.wait(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want a deadline error", err)
+	}
+}
+
+func TestRateLimiterMaxInFlight(t *testing.T) {
+	l := NewRateLimiter(RateLimit{MaxInFlight: 1})
+	release, err := l.Acquire(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, 10); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second acquire: err = %v, want it to block", err)
+	}
+
+	release(10)
+	second, err := l.Acquire(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	second(0)
+}
+
+func TestRateLimiterFailedAcquireFreesSlot(t *testing.T) {
+	l := NewRateLimiter(RateLimit{RPM: 1, MaxInFlight: 1})
+	release, err := l.Acquire(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release(1)
+
+	// The request budget is spent, so this waits and times out.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, 1); err == nil {
+		t.Fatal("acquire beyond RPM succeeded")
+	}
+	if len(l.slots) != 0 {
+		t.Errorf("failed acquire kept its in-flight slot")
+	}
+}
+
+func TestSharedRateLimiter(t *testing.T) {
+	a := SharedRateLimiter("openai", "https://api.example.com", "key-1", RateLimit{RPM: 10})
+	if b := SharedRateLimiter("openai", "https://api.example.com", "key-1", RateLimit{RPM: 99}); b != a {
+		t.Error("same account got a second limiter")
+	}
+	if b := SharedRateLimiter("openai", "https://api.example.com", "key-2", RateLimit{RPM: 10}); b == a {
+		t.Error("different keys share a limiter")
+	}
+	if b := SharedRateLimiter("anthropic", "https://api.example.com", "key-1", RateLimit{RPM: 10}); b == a {
+		t.Error("different providers share a limiter")
+	}
+}
+
+func TestRateLimitedProviderChargesUsage(t *testing.T) {
+	fake := NewFakeProvider(strings.Repeat("x", 400))
+	limiter := NewRateLimiter(RateLimit{TPM: 6000})
+	p := NewRateLimitedProvider(fake, limiter)
+
+	req := &ChatRequest{Messages: []ChatMessage{{Role: "user", Content: "abcd"}}}
+	if _, err := p.Chat(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	// The estimate is replaced by the 101 tokens the fake reports.
+	limiter.tokens.mu.Lock()
+	left := limiter.tokens.tokens
+	limiter.tokens.mu.Unlock()
+	if want := 6000.0 - 101; left < want || left > want+1 {
+		t.Errorf("tokens left = %.1f, want about %.0f", left, want)
+	}
+}
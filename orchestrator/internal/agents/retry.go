@@ -0,0 +1,206 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIError is a non-200 response from a provider.
+type APIError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // from the Retry-After header, if any
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API returned %d: %s", e.StatusCode, e.Body)
+}
+
+// TransportError is a failure to reach a provider or to read its response.
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// newAPIError builds an APIError from a response whose body has been read.
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter accepts both delay-seconds and HTTP-date values.
+func parseRetryAfter(val string) time.Duration {
+	if val == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(val); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(val); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// RetryPolicy decides which failed calls are retried and how long to wait.
+// 429 and 5xx responses are retried, honoring Retry-After; transport errors
+// are retried with jittered exponential backoff; everything else, including
+// 4xx auth and validation errors, fails immediately. A fallback chain is
+// retried if any of its providers failed in a retryable way.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64 // fraction of the backoff randomized, 0-1
+}
+
+// DefaultRetryPolicy is used by agents that are not configured otherwise.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   2 * time.Second,
+		MaxDelay:    60 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+// StatusCode returns the HTTP status of err, 0 if it has none.
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// delay returns how long to wait before the next attempt and whether the
+// error is worth retrying at all.
+func (p RetryPolicy) delay(err error, attempt int) (time.Duration, bool) {
+	backoff := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if p.Jitter > 0 {
+		backoff *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	wait := time.Duration(backoff)
+	if p.MaxDelay > 0 && wait > p.MaxDelay {
+		wait = p.MaxDelay
+	}
+
+	var fallbackErr *FallbackError
+	var apiErr *APIError
+	var transportErr *TransportError
+	switch {
+	case errors.As(err, &fallbackErr):
+		// Retry the chain if any provider may recover, as soon as the
+		// first of them is expected to.
+		retryable := false
+		for _, a := range fallbackErr.Attempts {
+			if a.Err == nil {
+				continue
+			}
+			if d, ok := p.delay(a.Err, attempt); ok && (!retryable || d < wait) {
+				wait, retryable = d, true
+			}
+		}
+		return wait, retryable
+	case errors.As(err, &apiErr):
+		if apiErr.StatusCode != http.StatusTooManyRequests && apiErr.StatusCode < 500 {
+			return 0, false
+		}
+		if apiErr.RetryAfter > 0 {
+			return apiErr.RetryAfter, true
+		}
+		return wait, true
+	case errors.As(err, &transportErr):
+		return wait, true
+	default:
+		return 0, false
+	}
+}
+
+// Do calls fn until it succeeds, fails with a non-retryable error, the
+// attempts run out or ctx is cancelled. Every attempt is logged.
+func (p RetryPolicy) Do(ctx context.Context, name string, fn func() error) error {
+	maxAttempts := max(p.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			log.Printf("[API] %s attempt %d/%d: status 200", name, attempt, maxAttempts)
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		wait, retryable := p.delay(err, attempt)
+		if !retryable || attempt >= maxAttempts {
+			log.Printf("[API] %s attempt %d/%d: status %d, giving up: %v",
+				name, attempt, maxAttempts, StatusCode(err), err)
+			if retryable {
+				return fmt.Errorf("failed after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+
+		log.Printf("[API] %s attempt %d/%d: status %d, retrying in %s: %v",
+			name, attempt, maxAttempts, StatusCode(err), wait.Round(time.Millisecond), err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryProvider applies a RetryPolicy to every call of a provider.
+type retryProvider struct {
+	Provider
+	name   string
+	policy RetryPolicy
+}
+
+func (p *retryProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	var resp *ChatResponse
+	err := p.policy.Do(ctx, p.name, func() error {
+		var err error
+		resp, err = p.Provider.Chat(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (p *retryProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	sp, ok := p.Provider.(StreamingProvider)
+	if !ok {
+		return p.Chat(ctx, req)
+	}
+
+	var resp *ChatResponse
+	err := p.policy.Do(ctx, p.name, func() error {
+		var err error
+		resp, err = sp.ChatStream(ctx, req, onDelta)
+		return err
+	})
+	return resp, err
+}
@@ -0,0 +1,118 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		val string
+		min time.Duration
+		max time.Duration
+	}{
+		{"", 0, 0},
+		{"7", 7 * time.Second, 7 * time.Second},
+		{"0", 0, 0},
+		{"-3", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), 28 * time.Second, 30 * time.Second},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.val); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s-%s", tt.val, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	tests := []struct {
+		name      string
+		err       error
+		attempt   int
+		wait      time.Duration
+		retryable bool
+	}{
+		{"429 backoff", &APIError{StatusCode: 429}, 1, time.Second, true},
+		{"429 Retry-After", &APIError{StatusCode: 429, RetryAfter: 9 * time.Second}, 1, 9 * time.Second, true},
+		{"500 second attempt", &APIError{StatusCode: 500}, 2, 2 * time.Second, true},
+		{"503 capped", &APIError{StatusCode: 503}, 5, 5 * time.Second, true},
+		{"400", &APIError{StatusCode: 400}, 1, 0, false},
+		{"401", &APIError{StatusCode: 401, RetryAfter: time.Second}, 1, 0, false},
+		{"404", &APIError{StatusCode: 404}, 1, 0, false},
+		{"wrapped 502", fmt.Errorf("engine: %w", &APIError{StatusCode: 502}), 1, time.Second, true},
+		{"transport", &TransportError{Err: errors.New("connection reset")}, 3, 4 * time.Second, true},
+		{"plain error", errors.New("bad JSON"), 1, 0, false},
+		{"context", context.Canceled, 1, 0, false},
+		{"chain all 4xx", &FallbackError{Attempts: []ProviderAttempt{
+			{Label: "a", Err: &APIError{StatusCode: 401}},
+			{Label: "b", Err: &APIError{StatusCode: 400}},
+		}}, 1, 0, false},
+		{"chain shortest wait", &FallbackError{Attempts: []ProviderAttempt{
+			{Label: "a", Err: &APIError{StatusCode: 429, RetryAfter: 30 * time.Second}},
+			{Label: "b", Skipped: true},
+			{Label: "c", Err: &APIError{StatusCode: 429, RetryAfter: 3 * time.Second}},
+		}}, 1, 3 * time.Second, true},
+		{"chain all skipped", &FallbackError{Attempts: []ProviderAttempt{{Label: "a", Skipped: true}}}, 1, 0, false},
+	}
+	for _, tt := range tests {
+		wait, retryable := p.delay(tt.err, tt.attempt)
+		if retryable != tt.retryable || retryable && wait != tt.wait {
+			t.Errorf("%s: delay = %s, %t; want %s, %t", tt.name, wait, retryable, tt.wait, tt.retryable)
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	tests := []struct {
+		name     string
+		errs     []error
+		attempts int
+		wantErr  bool
+	}{
+		{"success", nil, 1, false},
+		{"recovers", []error{&APIError{StatusCode: 503}, &TransportError{Err: errors.New("EOF")}}, 3, false},
+		{"fails fast on 401", []error{&APIError{StatusCode: 401}, nil}, 1, true},
+		{"runs out", []error{&APIError{StatusCode: 500}, &APIError{StatusCode: 500}, &APIError{StatusCode: 500}, nil}, 3, true},
+	}
+	for _, tt := range tests {
+		attempts := 0
+		err := p.Do(context.Background(), "test", func() error {
+			attempts++
+			if attempts <= len(tt.errs) {
+				return tt.errs[attempts-1]
+			}
+			return nil
+		})
+		if attempts != tt.attempts || (err != nil) != tt.wantErr {
+			t.Errorf("%s: %d attempts, err %v; want %d attempts, error %t", tt.name, attempts, err, tt.attempts, tt.wantErr)
+		}
+		if tt.wantErr && StatusCode(err) == 0 {
+			t.Errorf("%s: status lost from %v", tt.name, err)
+		}
+	}
+}
+
+func TestRetryPolicyDoStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour}
+	attempts := 0
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err := p.Do(ctx, "test", func() error {
+		attempts++
+		return &APIError{StatusCode: 503}
+	})
+	if !errors.Is(err, context.Canceled) || attempts != 1 {
+		t.Errorf("err = %v after %d attempts, want context.Canceled after 1", err, attempts)
+	}
+}
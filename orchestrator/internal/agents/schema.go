@@ -0,0 +1,170 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema the agents use to describe and
+// validate structured output: types, properties, required fields, enums and
+// minimum string length.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	MinLength            int                `json:"minLength,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+}
+
+// MustParseSchema parses a schema literal and panics if it is invalid.
+func MustParseSchema(src string) *Schema {
+	var s Schema
+	if err := json.Unmarshal([]byte(src), &s); err != nil {
+		panic(fmt.Sprintf("agents: invalid schema: %v", err))
+	}
+	return &s
+}
+
+// Validate checks a JSON document against the schema and returns every
+// violation found, joined into one error.
+func (s *Schema) Validate(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("not valid JSON: %w", err)
+	}
+
+	var problems []string
+	s.validate("$", v, &problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (s *Schema) validate(path string, v any, problems *[]string) {
+	fail := func(format string, args ...any) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			fail("expected object, got %s", jsonType(v))
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				fail("missing required field %q", name)
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					fail("unexpected field %q", name)
+				}
+				continue
+			}
+			prop.validate(path+"."+name, obj[name], problems)
+		}
+
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			fail("expected string, got %s", jsonType(v))
+			return
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			fail("%q is not one of %s", str, strings.Join(s.Enum, ", "))
+		}
+		if len(strings.TrimSpace(str)) < s.MinLength {
+			fail("must not be empty")
+		}
+
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok {
+			fail("expected %s, got %s", s.Type, jsonType(v))
+			return
+		}
+		if s.Type == "integer" && n != float64(int64(n)) {
+			fail("expected integer, got %v", n)
+		}
+
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail("expected boolean, got %s", jsonType(v))
+		}
+
+	case "array":
+		if _, ok := v.([]any); !ok {
+			fail("expected array, got %s", jsonType(v))
+		}
+	}
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// ExtractJSON finds the first JSON object in model output. It tolerates
+// markdown fences and prose around the object.
+func ExtractJSON(text string) (string, error) {
+	text = strings.TrimSpace(text)
+
+	start := strings.IndexByte(text, '{')
+	if start < 0 {
+		return "", fmt.Errorf("no JSON object found")
+	}
+
+	depth := 0
+	inString := false
+	escaped := false
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return text[start : i+1], nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("unterminated JSON object")
+}
@@ -0,0 +1,83 @@
+package agents
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`{"a":1}`, `{"a":1}`},
+		{"Here you go:\n```json\n{\"a\": {\"b\": \"}\"}}\n```\nThanks", `{"a": {"b": "}"}}`},
+		{`prefix {"s":"quote \" and { brace"} suffix {"x":2}`, `{"s":"quote \" and { brace"}`},
+	}
+	for _, tt := range tests {
+		got, err := ExtractJSON(tt.text)
+		if err != nil || got != tt.want {
+			t.Errorf("ExtractJSON(%q) = %q, %v, want %q", tt.text, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"no json here", `{"a": "unterminated`} {
+		if _, err := ExtractJSON(bad); err == nil {
+			t.Errorf("ExtractJSON(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestDebugResultSchema(t *testing.T) {
+	tests := []struct {
+		doc      string
+		problems []string // substrings of the error; none means valid
+	}{
+		{`{"analysis":"nil map","fix_type":"code_patch","fix_content":"--- a/x\n+++ b/x"}`, nil},
+		{`{"analysis":"x","fix_type":"rewrite","fix_content":"y"}`, []string{`$.fix_type: "rewrite" is not one of`}},
+		{`{"analysis":"x","fix_type":"command"}`, []string{`missing required field "fix_content"`}},
+		{`{"analysis":"  ","fix_type":"command","fix_content":"go build"}`, []string{"$.analysis: must not be empty"}},
+		{`{"analysis":1,"fix_type":"command","fix_content":["a"]}`, []string{"$.analysis: expected string, got number", "$.fix_content: expected string, got array"}},
+		{`[]`, []string{"expected object, got array"}},
+		{`{`, []string{"not valid JSON"}},
+	}
+	for _, tt := range tests {
+		err := DebugResultSchema.Validate([]byte(tt.doc))
+		if len(tt.problems) == 0 {
+			if err != nil {
+				t.Errorf("Validate(%s) = %v", tt.doc, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("Validate(%s) succeeded", tt.doc)
+			continue
+		}
+		for _, p := range tt.problems {
+			if !strings.Contains(err.Error(), p) {
+				t.Errorf("Validate(%s) = %v, want it to mention %q", tt.doc, err, p)
+			}
+		}
+	}
+}
+
+func TestSchemaAdditionalProperties(t *testing.T) {
+	s := MustParseSchema(`{"type":"object","properties":{"n":{"type":"integer"}},"additionalProperties":false}`)
+	if err := s.Validate([]byte(`{"n":2}`)); err != nil {
+		t.Errorf("valid document: %v", err)
+	}
+	err := s.Validate([]byte(`{"n":2.5,"extra":true}`))
+	if err == nil || !strings.Contains(err.Error(), "expected integer") || !strings.Contains(err.Error(), `unexpected field "extra"`) {
+		t.Errorf("Validate = %v", err)
+	}
+}
+
+func TestParseDebugResult(t *testing.T) {
+	result, err := parseDebugResult("```json\n{\"analysis\":\"a\",\"fix_type\":\"command\",\"fix_content\":\"go mod tidy\"}\n```")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FixType != FixTypeCommand || result.FixContent != "go mod tidy" {
+		t.Errorf("result = %+v", result)
+	}
+}
@@ -0,0 +1,136 @@
+package agents
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// StreamingProvider is implemented by providers that can deliver a response
+// incrementally. onDelta is called with each chunk of content as it arrives.
+type StreamingProvider interface {
+	Provider
+	ChatStream(ctx context.Context, req *ChatRequest, onDelta func(string)) (*ChatResponse, error)
+}
+
+// DefaultIdleTimeout is the longest a stream may go without a chunk.
+const DefaultIdleTimeout = 60 * time.Second
+
+type streamHandlerKey struct{}
+
+// WithStreamHandler returns a context that delivers streamed content from any
+// agent call made with it to fn.
+func WithStreamHandler(ctx context.Context, fn func(delta string)) context.Context {
+	return context.WithValue(ctx, streamHandlerKey{}, fn)
+}
+
+func streamHandler(ctx context.Context) func(string) {
+	if fn, ok := ctx.Value(streamHandlerKey{}).(func(string)); ok && fn != nil {
+		return fn
+	}
+	return func(string) {}
+}
+
+// readSSE reads a text/event-stream body and calls fn for every event.
+// Returning io.EOF from fn stops reading without an error.
+func readSSE(r io.Reader, fn func(event, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	var event string
+	var data []string
+	dispatch := func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		err := fn(event, strings.Join(data, "\n"))
+		event, data = "", nil
+		return err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if err := dispatch(); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// comment / keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := dispatch(); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// idleTimer cancels a stream that goes quiet for longer than its timeout.
+type idleTimer struct {
+	timeout time.Duration
+	timer   *time.Timer
+	fired   atomic.Bool
+}
+
+// newIdleTimer derives a context that is cancelled when Touch is not called
+// within timeout. Call stop once the stream is finished.
+func newIdleTimer(ctx context.Context, timeout time.Duration) (context.Context, *idleTimer, func()) {
+	if timeout <= 0 {
+		timeout = DefaultIdleTimeout
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	t := &idleTimer{timeout: timeout}
+	t.timer = time.AfterFunc(timeout, func() {
+		t.fired.Store(true)
+		cancel()
+	})
+
+	return ctx, t, func() {
+		t.timer.Stop()
+		cancel()
+	}
+}
+
+// Touch records activity on the stream.
+func (t *idleTimer) Touch() {
+	t.timer.Reset(t.timeout)
+}
+
+// Err wraps err with an idle-timeout message if the timer fired.
+func (t *idleTimer) Err(err error) error {
+	if t.fired.Load() {
+		return fmt.Errorf("stream idle for more than %s: %w", t.timeout, err)
+	}
+	return err
+}
+
+// touchReader resets the idle timer on every successful read.
+type touchReader struct {
+	r     io.Reader
+	timer *idleTimer
+}
+
+func (tr *touchReader) Read(p []byte) (int, error) {
+	n, err := tr.r.Read(p)
+	if n > 0 {
+		tr.timer.Touch()
+	}
+	return n, err
+}
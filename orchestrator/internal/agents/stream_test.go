@@ -0,0 +1,66 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChatStreamTruncated(t *testing.T) {
+	const (
+		openAIChunk = "data: {\"choices\":[{\"delta\":{\"content\":\"--- a/x.go\\n\"}}]}\n\n"
+		openAIDone  = "data: [DONE]\n\n"
+
+		ollamaChunk = `{"message":{"role":"assistant","content":"--- a/x.go\n"},"done":false}` + "\n"
+		ollamaDone  = `{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","eval_count":3}` + "\n"
+
+		anthropicChunk = "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"--- a/x.go\\n\"}}\n\n"
+		anthropicDone  = "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+	)
+	tests := []struct {
+		provider string
+		body     string
+		ok       bool
+	}{
+		{ProviderOpenAI, openAIChunk + openAIDone, true},
+		{ProviderOpenAI, openAIChunk, false},
+		{ProviderOpenAI, "", false},
+		{ProviderOllama, ollamaChunk + ollamaDone, true},
+		{ProviderOllama, ollamaChunk, false},
+		{ProviderAnthropic, anthropicChunk + anthropicDone, true},
+		{ProviderAnthropic, anthropicChunk, false},
+	}
+
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(tt.body))
+		}))
+		p, err := NewProvider(tt.provider, ProviderConfig{APIKey: "k", APIURL: srv.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var streamed strings.Builder
+		resp, err := p.(StreamingProvider).ChatStream(context.Background(), &ChatRequest{
+			Messages: []ChatMessage{{Role: "user", Content: "fix it"}},
+		}, func(s string) { streamed.WriteString(s) })
+		srv.Close()
+
+		if tt.ok {
+			if err != nil || resp.Content() != "--- a/x.go\n" {
+				t.Errorf("%s complete stream: resp %+v, err %v", tt.provider, resp, err)
+			}
+			continue
+		}
+		var transportErr *TransportError
+		if !errors.As(err, &transportErr) {
+			t.Errorf("%s truncated stream %q: err = %v, want a TransportError", tt.provider, tt.body, err)
+		}
+		if resp != nil {
+			t.Errorf("%s truncated stream returned %q", tt.provider, resp.Content())
+		}
+	}
+}
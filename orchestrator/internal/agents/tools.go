@@ -0,0 +1,130 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ToolHandler executes a tool call. args is the raw JSON arguments object.
+type ToolHandler func(ctx context.Context, args json.RawMessage) (string, error)
+
+// Tool is a function the model may call, together with its Go implementation.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage // JSON schema of the arguments object
+	Handler     ToolHandler
+}
+
+// Spec returns the tool description sent to the model.
+func (t Tool) Spec() ToolSpec {
+	return ToolSpec{
+		Type: "function",
+		Function: ToolFunction{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  t.Parameters,
+		},
+	}
+}
+
+// ErrToolsDone can be returned by a handler to end the tool loop after the
+// current turn, e.g. once a final answer tool has been called.
+var ErrToolsDone = errors.New("tool loop done")
+
+// ErrMaxToolIterations is returned when the model keeps calling tools past
+// the iteration limit.
+var ErrMaxToolIterations = errors.New("tool loop exceeded max iterations")
+
+// RunTools sends req to the provider and executes the tool calls in each
+// reply, feeding results back until the model answers with plain content, a
+// handler returns ErrToolsDone, or maxIterations turns have been used. It
+// returns the last response, with usage summed over all turns, and the full
+// conversation. On error the response, if not nil, holds only the usage of
+// the turns that completed, so it can still be accounted for.
+func RunTools(ctx context.Context, provider Provider, req *ChatRequest, tools []Tool, maxIterations int) (*ChatResponse, []ChatMessage, error) {
+	byName := make(map[string]Tool, len(tools))
+	req.Tools = req.Tools[:0]
+	for _, t := range tools {
+		byName[t.Name] = t
+		req.Tools = append(req.Tools, t.Spec())
+	}
+	if req.ToolChoice == "" {
+		req.ToolChoice = ToolChoiceAuto
+	}
+
+	messages := append([]ChatMessage(nil), req.Messages...)
+	var usage Usage
+	var model string
+	spent := func() *ChatResponse {
+		if usage == (Usage{}) {
+			return nil
+		}
+		return &ChatResponse{Model: model, Usage: usage}
+	}
+
+	for iter := 0; iter < maxIterations; iter++ {
+		turn := *req
+		turn.Messages = messages
+
+		resp, err := provider.Chat(ctx, &turn)
+		if err != nil {
+			return spent(), messages, err
+		}
+		usage, model = usage.Add(resp.Usage), resp.Model
+		if len(resp.Choices) == 0 {
+			return spent(), messages, fmt.Errorf("no choices in API response")
+		}
+		resp.Usage = usage
+
+		reply := resp.Choices[0].Message
+		reply.Role = "assistant"
+		messages = append(messages, reply)
+
+		if len(reply.ToolCalls) == 0 {
+			return resp, messages, nil
+		}
+
+		done := false
+		for _, call := range reply.ToolCalls {
+			output, err := runToolCall(ctx, byName, call)
+			switch {
+			case errors.Is(err, ErrToolsDone):
+				done = true
+			case err != nil:
+				output = "error: " + err.Error()
+			}
+
+			messages = append(messages, ChatMessage{
+				Role:       "tool",
+				Content:    output,
+				ToolCallID: call.ID,
+			})
+		}
+
+		if done {
+			return resp, messages, nil
+		}
+	}
+
+	return spent(), messages, fmt.Errorf("%w (%d)", ErrMaxToolIterations, maxIterations)
+}
+
+func runToolCall(ctx context.Context, tools map[string]Tool, call ToolCall) (string, error) {
+	tool, ok := tools[call.Function.Name]
+	if !ok {
+		return "", fmt.Errorf("unknown tool %q", call.Function.Name)
+	}
+
+	args := json.RawMessage(call.Function.Arguments)
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	if !json.Valid(args) {
+		return "", fmt.Errorf("arguments for %s are not valid JSON", call.Function.Name)
+	}
+
+	return tool.Handler(ctx, args)
+}
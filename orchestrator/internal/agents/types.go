@@ -1,6 +1,9 @@
 package agents
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+)
 
 // Agent is the interface all LLM agents must implement.
 type Agent interface {
@@ -8,27 +11,132 @@ type Agent interface {
 	Execute(ctx context.Context, prompt string) (string, error)
 }
 
+// Names of the built-in LLM providers.
+const (
+	ProviderOpenAI    = "openai"    // OpenAI-compatible chat completions (DeepSeek, GLM, ...)
+	ProviderAnthropic = "anthropic" // Anthropic Messages API
+	ProviderOllama    = "ollama"    // Local Ollama server
+	ProviderFake      = "fake"      // Scripted responses, no network
+)
+
 // ChatMessage represents a message in a conversation.
 type ChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // assistant messages requesting tools
+	ToolCallID string     `json:"tool_call_id,omitempty"` // "tool" messages answering a call
+}
+
+// ToolCall is a function call requested by the model.
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"` // always "function"
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction names the function to call and its JSON-encoded arguments.
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolSpec describes a tool to the model.
+type ToolSpec struct {
+	Type     string       `json:"type"` // always "function"
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction is the name, description and JSON schema of a tool.
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
 }
 
+// Values for ChatRequest.ToolChoice.
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceRequired = "required"
+	ToolChoiceNone     = "none"
+)
+
 // ChatRequest is the request body for chat completion APIs.
 type ChatRequest struct {
 	Model       string        `json:"model"`
 	Messages    []ChatMessage `json:"messages"`
 	Temperature float64       `json:"temperature,omitempty"`
 	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Stream      bool          `json:"stream,omitempty"`
+	Tools       []ToolSpec    `json:"tools,omitempty"`
+	ToolChoice  string        `json:"tool_choice,omitempty"`
+
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+}
+
+// StreamOptions asks OpenAI-compatible APIs to report usage in the last chunk.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// ChatChoice is a single completion candidate.
+type ChatChoice struct {
+	Message      ChatMessage `json:"message"`
+	FinishReason string      `json:"finish_reason,omitempty"`
 }
 
-// ChatResponse is the response from chat completion APIs.
+// ChatResponse is the response from chat completion APIs. Providers that use
+// a different wire format convert their responses into this shape.
 type ChatResponse struct {
-	Choices []struct {
-		Message ChatMessage `json:"message"`
-	} `json:"choices"`
+	Model   string       `json:"model,omitempty"`
+	Choices []ChatChoice `json:"choices"`
+	Usage   Usage        `json:"usage"`
+}
+
+// Usage is the token count of a single request.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Add returns the sum of two usages.
+func (u Usage) Add(o Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + o.PromptTokens,
+		CompletionTokens: u.CompletionTokens + o.CompletionTokens,
+		TotalTokens:      u.TotalTokens + o.TotalTokens,
+	}
+}
+
+// Content returns the text of the first choice.
+func (r *ChatResponse) Content() string {
+	if len(r.Choices) == 0 {
+		return ""
+	}
+	return r.Choices[0].Message.Content
 }
 
+// Values of DebugResult.FixType.
+const (
+	FixTypeCodePatch    = "code_patch"
+	FixTypeCommand      = "command"
+	FixTypeConfigChange = "config_change"
+)
+
+// debugResultSchema is the JSON schema every DebugResult must satisfy.
+const debugResultSchema = `{
+  "type": "object",
+  "properties": {
+    "analysis": {"type": "string", "minLength": 1, "description": "Brief description of the root cause"},
+    "fix_type": {"type": "string", "enum": ["code_patch", "command", "config_change"]},
+    "fix_content": {"type": "string", "minLength": 1, "description": "The exact fix to apply (code diff, command to run, or config to change)"}
+  },
+  "required": ["analysis", "fix_type", "fix_content"]
+}`
+
+// DebugResultSchema validates debugger output.
+var DebugResultSchema = MustParseSchema(debugResultSchema)
+
 // DebugResult is the structured output from the debugger agent.
 type DebugResult struct {
 	Analysis   string `json:"analysis"`
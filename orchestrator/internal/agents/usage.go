@@ -0,0 +1,188 @@
+package agents
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Price is the cost of a model in USD per million tokens.
+type Price struct {
+	InputPerMTok  float64
+	OutputPerMTok float64
+}
+
+// PriceTable maps model names to prices.
+type PriceTable map[string]Price
+
+// DefaultPrices covers the models the orchestrator is configured with out of
+// the box. Override or extend them with ParsePriceTable.
+var DefaultPrices = PriceTable{
+	"deepseek-chat":     {InputPerMTok: 0.27, OutputPerMTok: 1.10},
+	"deepseek-reasoner": {InputPerMTok: 0.55, OutputPerMTok: 2.19},
+	"claude-sonnet-4-5": {InputPerMTok: 3, OutputPerMTok: 15},
+	"claude-haiku-4-5":  {InputPerMTok: 1, OutputPerMTok: 5},
+}
+
+// ParsePriceTable parses "model=input/output,..." with prices in USD per
+// million tokens, layered over DefaultPrices.
+func ParsePriceTable(spec string) (PriceTable, error) {
+	table := make(PriceTable, len(DefaultPrices))
+	for model, price := range DefaultPrices {
+		table[model] = price
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		model, prices, ok := strings.Cut(entry, "=")
+		in, out, ok2 := strings.Cut(prices, "/")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid price entry %q (want model=input/output)", entry)
+		}
+
+		inPrice, err := strconv.ParseFloat(strings.TrimSpace(in), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input price in %q: %w", entry, err)
+		}
+		outPrice, err := strconv.ParseFloat(strings.TrimSpace(out), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output price in %q: %w", entry, err)
+		}
+
+		table[strings.TrimSpace(model)] = Price{InputPerMTok: inPrice, OutputPerMTok: outPrice}
+	}
+
+	return table, nil
+}
+
+// Cost returns the USD cost of usage on model. Unknown models are free.
+func (t PriceTable) Cost(model string, u Usage) float64 {
+	price, ok := t[model]
+	if !ok {
+		return 0
+	}
+	return (float64(u.PromptTokens)*price.InputPerMTok + float64(u.CompletionTokens)*price.OutputPerMTok) / 1e6
+}
+
+// UsageTotals accumulates token usage and cost.
+type UsageTotals struct {
+	Calls            int
+	PromptTokens     int
+	CompletionTokens int
+	CostUSD          float64
+}
+
+func (t *UsageTotals) add(u Usage, cost float64) {
+	t.Calls++
+	t.PromptTokens += u.PromptTokens
+	t.CompletionTokens += u.CompletionTokens
+	t.CostUSD += cost
+}
+
+// Budget scopes.
+const (
+	BudgetScopeTask = "task"
+	BudgetScopeRun  = "run"
+)
+
+// BudgetExceededError is returned once a task or run has spent its budget.
+type BudgetExceededError struct {
+	Scope    string // BudgetScopeTask or BudgetScopeRun
+	TaskID   string
+	LimitUSD float64
+	SpentUSD float64
+}
+
+func (e *BudgetExceededError) Error() string {
+	if e.Scope == BudgetScopeTask {
+		return fmt.Sprintf("task %s budget exceeded: spent $%.4f of $%.4f", e.TaskID, e.SpentUSD, e.LimitUSD)
+	}
+	return fmt.Sprintf("run budget exceeded: spent $%.4f of $%.4f", e.SpentUSD, e.LimitUSD)
+}
+
+// Ledger records the token usage of every agent call and enforces per-task
+// and per-run cost budgets. A zero budget is unlimited.
+type Ledger struct {
+	mu         sync.Mutex
+	prices     PriceTable
+	taskBudget float64
+	runBudget  float64
+	taskID     string
+	task       UsageTotals
+	run        UsageTotals
+}
+
+func NewLedger(prices PriceTable, taskBudgetUSD, runBudgetUSD float64) *Ledger {
+	return &Ledger{
+		prices:     prices,
+		taskBudget: taskBudgetUSD,
+		runBudget:  runBudgetUSD,
+	}
+}
+
+// StartTask resets the per-task totals.
+func (l *Ledger) StartTask(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.taskID = id
+	l.task = UsageTotals{}
+}
+
+// Record adds the usage of one call and returns a *BudgetExceededError if a
+// budget has now been spent.
+func (l *Ledger) Record(agent, model string, u Usage) error {
+	return l.RecordCost(agent, model, u, l.prices.Cost(model, u))
+}
+
+// RecordCost is Record for calls whose cost is already known, such as the
+// total a coding CLI reports for its session.
+func (l *Ledger) RecordCost(agent, model string, u Usage, cost float64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.task.add(u, cost)
+	l.run.add(u, cost)
+
+	log.Printf("[USAGE] %s (%s): %d prompt + %d completion tokens, $%.4f (task $%.4f, run $%.4f)",
+		agent, model, u.PromptTokens, u.CompletionTokens, cost, l.task.CostUSD, l.run.CostUSD)
+
+	return l.checkLocked()
+}
+
+// Check returns a *BudgetExceededError if a budget is already spent. Agents
+// call it before each request.
+func (l *Ledger) Check() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.checkLocked()
+}
+
+func (l *Ledger) checkLocked() error {
+	if l.runBudget > 0 && l.run.CostUSD >= l.runBudget {
+		return &BudgetExceededError{Scope: BudgetScopeRun, TaskID: l.taskID, LimitUSD: l.runBudget, SpentUSD: l.run.CostUSD}
+	}
+	if l.taskBudget > 0 && l.task.CostUSD >= l.taskBudget {
+		return &BudgetExceededError{Scope: BudgetScopeTask, TaskID: l.taskID, LimitUSD: l.taskBudget, SpentUSD: l.task.CostUSD}
+	}
+	return nil
+}
+
+// TaskTotals returns the usage of the current task.
+func (l *Ledger) TaskTotals() UsageTotals {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.task
+}
+
+// RunTotals returns the usage of the whole run.
+func (l *Ledger) RunTotals() UsageTotals {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.run
+}
@@ -1,17 +1,92 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	// Engine agent (DeepSeek-V3 / GLM-4)
-	EngineAPIKey string
-	EngineAPIURL string
-	EngineModel  string
+	EngineProvider string
+	EngineAPIKey   string
+	EngineAPIURL   string
+	EngineModel    string
 
 	// Debugger agent (DeepSeek)
-	DebuggerAPIKey string
-	DebuggerAPIURL string
-	DebuggerModel  string
+	DebuggerProvider string
+	DebuggerAPIKey   string
+	DebuggerAPIURL   string
+	DebuggerModel    string
+
+	// Fallback chains tried in order when the primary provider fails
+	EngineFallbacks   []ProviderSpec
+	DebuggerFallbacks []ProviderSpec
+	BreakerThreshold  int
+	BreakerCooldown   time.Duration
+
+	// Client-side rate limits per provider name
+	RateLimits map[string]RateLimit
+
+	// Retry policy per agent: 429/5xx and transport errors are retried
+	EngineMaxAttempts      int
+	EngineRetryBaseDelay   time.Duration
+	EngineRetryMaxDelay    time.Duration
+	DebuggerMaxAttempts    int
+	DebuggerRetryBaseDelay time.Duration
+	DebuggerRetryMaxDelay  time.Duration
+
+	// Debugger tool calling
+	DebuggerTools         bool
+	DebuggerMaxToolRounds int
+	DebuggerRepairTurns   int // follow-ups sent when output fails schema validation
+	DebuggerMemoryTurns   int // past fix attempts replayed in full
+
+	// Programs and denied prefixes for Debugger command fixes; empty uses
+	// the built-in lists
+	FixCommandAllow []string
+	FixCommandDeny  []string
+
+	// Execution backends; tasks may override ExecBackend
+	ExecBackend       string // claude, cli or native
+	ExecCLICommand    string // command template for the cli backend
+	ExecFreshSessions bool   // never resume a task's Claude CLI session
+
+	// Spawned commands
+	ExecKillGrace      time.Duration // SIGTERM → SIGKILL delay for cancelled commands
+	ExecMaxOutputBytes int           // per stream; head and tail are kept
+
+	// Streaming
+	EngineStream      bool
+	DebuggerStream    bool
+	StreamIdleTimeout time.Duration
+
+	// Context windows in tokens, per model
+	ContextWindows       map[string]int
+	DefaultContextWindow int
+	ExecutionerContext   int
+
+	// Code retrieval for plan and debug prompts
+	RetrievalEnabled bool
+	RetrievalTopK    int
+	RetrievalTokens  int
+
+	// Go declaration context for backend tasks
+	GoContextEnabled bool
+	GoContextTokens  int
+
+	// Response cache (opt-in)
+	CacheEnabled      bool
+	CacheDir          string
+	CacheTTL          time.Duration
+	CacheMaxMB        int
+	CacheAllowSampled bool // cache requests with temperature > 0
+
+	// Cost accounting; budgets of 0 are unlimited
+	ModelPrices   string // "model=input/output,..." in USD per million tokens
+	TaskBudgetUSD float64
+	RunBudgetUSD  float64
 
 	// Project
 	ProjectRoot string
@@ -23,15 +98,88 @@ type Config struct {
 	TestCommandWeb string
 }
 
+// ProviderSpec is a provider/model pair with its credentials.
+type ProviderSpec struct {
+	Provider string
+	Model    string
+	APIKey   string
+	APIURL   string
+}
+
+// RateLimit caps requests/tokens per minute and concurrent requests for a
+// provider account. Zero values are unlimited.
+type RateLimit struct {
+	RPM         int
+	TPM         int
+	MaxInFlight int
+}
+
 func Load() *Config {
 	return &Config{
-		EngineAPIKey: getEnv("ENGINE_API_KEY", ""),
-		EngineAPIURL: getEnv("ENGINE_API_URL", "https://api.deepseek.com/v1/chat/completions"),
-		EngineModel:  getEnv("ENGINE_MODEL", "deepseek-chat"),
+		// Empty URLs and models fall back to the provider's defaults.
+		EngineProvider: getEnv("ENGINE_PROVIDER", "openai"),
+		EngineAPIKey:   getEnv("ENGINE_API_KEY", ""),
+		EngineAPIURL:   getEnv("ENGINE_API_URL", ""),
+		EngineModel:    getEnv("ENGINE_MODEL", ""),
+
+		DebuggerProvider: getEnv("DEBUGGER_PROVIDER", "openai"),
+		DebuggerAPIKey:   getEnv("DEBUGGER_API_KEY", ""),
+		DebuggerAPIURL:   getEnv("DEBUGGER_API_URL", ""),
+		DebuggerModel:    getEnv("DEBUGGER_MODEL", ""),
+
+		EngineFallbacks:   parseProviderSpecs(getEnv("ENGINE_FALLBACKS", "")),
+		DebuggerFallbacks: parseProviderSpecs(getEnv("DEBUGGER_FALLBACKS", "")),
+		BreakerThreshold:  getEnvInt("BREAKER_THRESHOLD", 3),
+		BreakerCooldown:   getEnvDuration("BREAKER_COOLDOWN", 2*time.Minute),
+
+		RateLimits: parseRateLimits(getEnv("RATE_LIMITS", "")),
+
+		EngineMaxAttempts:      getEnvInt("ENGINE_MAX_ATTEMPTS", 3),
+		EngineRetryBaseDelay:   getEnvDuration("ENGINE_RETRY_BASE_DELAY", 2*time.Second),
+		EngineRetryMaxDelay:    getEnvDuration("ENGINE_RETRY_MAX_DELAY", 60*time.Second),
+		DebuggerMaxAttempts:    getEnvInt("DEBUGGER_MAX_ATTEMPTS", 3),
+		DebuggerRetryBaseDelay: getEnvDuration("DEBUGGER_RETRY_BASE_DELAY", 2*time.Second),
+		DebuggerRetryMaxDelay:  getEnvDuration("DEBUGGER_RETRY_MAX_DELAY", 60*time.Second),
+
+		DebuggerTools:         getEnvBool("DEBUGGER_TOOLS", true),
+		DebuggerMaxToolRounds: getEnvInt("DEBUGGER_MAX_TOOL_ROUNDS", 8),
+		DebuggerRepairTurns:   getEnvInt("DEBUGGER_REPAIR_TURNS", 2),
+		DebuggerMemoryTurns:   getEnvInt("DEBUGGER_MEMORY_TURNS", 3),
+
+		FixCommandAllow: parseList(getEnv("FIX_COMMAND_ALLOW", "")),
+		FixCommandDeny:  parseList(getEnv("FIX_COMMAND_DENY", "")),
+
+		ExecBackend:       getEnv("EXEC_BACKEND", "claude"),
+		ExecCLICommand:    getEnv("EXEC_CLI_COMMAND", ""),
+		ExecFreshSessions: getEnvBool("EXEC_FRESH_SESSIONS", false),
 
-		DebuggerAPIKey: getEnv("DEBUGGER_API_KEY", ""),
-		DebuggerAPIURL: getEnv("DEBUGGER_API_URL", "https://api.deepseek.com/v1/chat/completions"),
-		DebuggerModel:  getEnv("DEBUGGER_MODEL", "deepseek-chat"),
+		ExecKillGrace:      getEnvDuration("EXEC_KILL_GRACE", 10*time.Second),
+		ExecMaxOutputBytes: getEnvInt("EXEC_MAX_OUTPUT_BYTES", 1<<20),
+
+		EngineStream:      getEnvBool("ENGINE_STREAM", true),
+		DebuggerStream:    getEnvBool("DEBUGGER_STREAM", false),
+		StreamIdleTimeout: getEnvDuration("STREAM_IDLE_TIMEOUT", 60*time.Second),
+
+		ContextWindows:       parseIntMap(getEnv("MODEL_CONTEXT_WINDOWS", "deepseek-chat=64000,deepseek-reasoner=64000,claude-sonnet-4-5=200000,claude-haiku-4-5=200000")),
+		DefaultContextWindow: getEnvInt("DEFAULT_CONTEXT_WINDOW", 32000),
+		ExecutionerContext:   getEnvInt("EXECUTIONER_CONTEXT_TOKENS", 150000),
+
+		RetrievalEnabled: getEnvBool("RETRIEVAL_ENABLED", true),
+		RetrievalTopK:    getEnvInt("RETRIEVAL_TOP_K", 8),
+		RetrievalTokens:  getEnvInt("RETRIEVAL_MAX_TOKENS", 4000),
+
+		GoContextEnabled: getEnvBool("GO_CONTEXT_ENABLED", true),
+		GoContextTokens:  getEnvInt("GO_CONTEXT_MAX_TOKENS", 3000),
+
+		CacheEnabled:      getEnvBool("CACHE_ENABLED", false),
+		CacheDir:          getEnv("CACHE_DIR", ".orchestrator-cache"),
+		CacheTTL:          getEnvDuration("CACHE_TTL", 7*24*time.Hour),
+		CacheMaxMB:        getEnvInt("CACHE_MAX_MB", 200),
+		CacheAllowSampled: getEnvBool("CACHE_ALLOW_SAMPLED", false),
+
+		ModelPrices:   getEnv("MODEL_PRICES", ""),
+		TaskBudgetUSD: getEnvFloat("TASK_BUDGET_USD", 0),
+		RunBudgetUSD:  getEnvFloat("RUN_BUDGET_USD", 0),
 
 		ProjectRoot: getEnv("PROJECT_ROOT", ".."),
 		TaskFile:    getEnv("TASK_FILE", "../task_list.json"),
@@ -42,9 +190,108 @@ func Load() *Config {
 	}
 }
 
+// parseProviderSpecs parses "provider:model,provider:model". Credentials come
+// from <PROVIDER>_API_KEY and <PROVIDER>_API_URL.
+func parseProviderSpecs(val string) []ProviderSpec {
+	var specs []ProviderSpec
+	for _, entry := range strings.Split(val, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		provider, model, _ := strings.Cut(entry, ":")
+		prefix := strings.ToUpper(strings.ReplaceAll(provider, "-", "_"))
+		specs = append(specs, ProviderSpec{
+			Provider: provider,
+			Model:    model,
+			APIKey:   getEnv(prefix+"_API_KEY", ""),
+			APIURL:   getEnv(prefix+"_API_URL", ""),
+		})
+	}
+	return specs
+}
+
+// parseRateLimits parses "provider=rpm/tpm/inflight,...". Missing or invalid
+// numbers are treated as unlimited.
+func parseRateLimits(val string) map[string]RateLimit {
+	limits := make(map[string]RateLimit)
+	for _, entry := range strings.Split(val, ",") {
+		provider, spec, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+
+		fields := strings.Split(spec, "/")
+		num := func(i int) int {
+			if i >= len(fields) {
+				return 0
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(fields[i]))
+			return n
+		}
+		limits[strings.TrimSpace(provider)] = RateLimit{RPM: num(0), TPM: num(1), MaxInFlight: num(2)}
+	}
+	return limits
+}
+
+// parseList parses "a,b,c", dropping empty entries.
+func parseList(val string) []string {
+	var list []string
+	for _, entry := range strings.Split(val, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// parseIntMap parses "key=int,key=int".
+func parseIntMap(val string) map[string]int {
+	m := make(map[string]int)
+	for _, entry := range strings.Split(val, ",") {
+		key, num, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(num)); err == nil {
+			m[strings.TrimSpace(key)] = n
+		}
+	}
+	return m
+}
+
 func getEnv(key, fallback string) string {
 	if val := os.Getenv(key); val != "" {
 		return val
 	}
 	return fallback
 }
+
+func getEnvBool(key string, fallback bool) bool {
+	if val, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return val
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if val, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return val
+	}
+	return fallback
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if val, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return val
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if val, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return val
+	}
+	return fallback
+}
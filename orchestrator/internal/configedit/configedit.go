@@ -0,0 +1,146 @@
+// Package configedit makes structured edits to the project's known config
+// files: go.mod, package.json, tsconfig*.json, .env.example and TOML files.
+// Edits keep the rest of the file, including key order, as it was.
+package configedit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/patch"
+)
+
+// Operations. set and delete work on every format; replace and drop_replace
+// are go.mod replace directives.
+const (
+	OpSet         = "set"
+	OpDelete      = "delete"
+	OpReplace     = "replace"
+	OpDropReplace = "drop_replace"
+)
+
+// Change is one structured config edit, as written by the Debugger:
+//
+//	{"file": "mobile/package.json", "op": "set", "key": "/dependencies/axios", "value": "^1.7.0"}
+//
+// Key is a JSON pointer for JSON files, a dotted section.key for TOML, a
+// variable for .env files, and a module path (or "go"/"toolchain") for
+// go.mod, where set takes a version.
+type Change struct {
+	File  string          `json:"file"`
+	Op    string          `json:"op"`
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func (c Change) String() string {
+	if c.Op == OpDelete || c.Op == OpDropReplace {
+		return fmt.Sprintf("%s: %s %s", c.File, c.Op, c.Key)
+	}
+	return fmt.Sprintf("%s: %s %s = %s", c.File, c.Op, c.Key, c.Value)
+}
+
+// Parse reads a change or a list of changes from JSON, ignoring a markdown
+// fence around it.
+func Parse(content string) ([]Change, error) {
+	content = strings.TrimSpace(content)
+	if start := strings.IndexAny(content, "[{"); start >= 0 {
+		content = content[start:]
+	}
+	if end := strings.LastIndexAny(content, "]}"); end >= 0 {
+		content = content[:end+1]
+	}
+
+	var changes []Change
+	if strings.HasPrefix(content, "[") {
+		if err := json.Unmarshal([]byte(content), &changes); err != nil {
+			return nil, fmt.Errorf("parse config changes: %w", err)
+		}
+	} else {
+		var c Change
+		if err := json.Unmarshal([]byte(content), &c); err != nil {
+			return nil, fmt.Errorf("parse config change: %w", err)
+		}
+		changes = []Change{c}
+	}
+
+	for _, c := range changes {
+		if c.File == "" || c.Op == "" || c.Key == "" {
+			return nil, fmt.Errorf("config change needs file, op and key: %s", c)
+		}
+	}
+	return changes, nil
+}
+
+// format returns how a config file is edited, or "" if it is not one this
+// package knows.
+func format(path string) string {
+	base := filepath.Base(path)
+	switch {
+	case base == "go.mod":
+		return "mod"
+	case base == "package.json", strings.HasPrefix(base, "tsconfig") && strings.HasSuffix(base, ".json"):
+		return "json"
+	case base == ".env.example":
+		return "env"
+	case strings.HasSuffix(base, ".toml"):
+		return "toml"
+	}
+	return ""
+}
+
+// Apply makes one change to a file below root.
+func Apply(root string, c Change) error {
+	kind := format(c.File)
+	if kind == "" {
+		return fmt.Errorf("%s is not a known config file", c.File)
+	}
+	full, err := patch.ResolveInRoot(root, c.File)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return err
+	}
+
+	var out []byte
+	switch kind {
+	case "mod":
+		out, err = editGoMod(data, c)
+	case "json":
+		out, err = editJSON(data, c)
+	case "env":
+		out, err = editEnv(data, c)
+	case "toml":
+		out, err = editTOML(data, c)
+	}
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(out, data) {
+		return fmt.Errorf("no change: %s", c)
+	}
+	return os.WriteFile(full, out, info.Mode().Perm())
+}
+
+// stringValue decodes a JSON string value, or returns other values as
+// written.
+func stringValue(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", fmt.Errorf("missing value")
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	return string(bytes.TrimSpace(raw)), nil
+}
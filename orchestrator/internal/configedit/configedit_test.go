@@ -0,0 +1,236 @@
+package configedit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func change(file, op, key, value string) Change {
+	c := Change{File: file, Op: op, Key: key}
+	if value != "" {
+		c.Value = json.RawMessage(value)
+	}
+	return c
+}
+
+func applyTo(t *testing.T, name, content string, c Change) (string, error) {
+	t.Helper()
+	root := t.TempDir()
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c.File = name
+	err := Apply(root, c)
+	data, readErr := os.ReadFile(path)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	return string(data), err
+}
+
+func TestParse(t *testing.T) {
+	changes, err := Parse("```json\n" + `[{"file":"package.json","op":"set","key":"/name","value":"x"},
+		{"file":"go.mod","op":"delete","key":"example.com/a"}]` + "\n```")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Key != "/name" || string(changes[0].Value) != `"x"` || changes[1].Op != OpDelete {
+		t.Errorf("changes = %+v", changes)
+	}
+
+	if changes, err := Parse(`{"file":".env.example","op":"set","key":"PORT","value":8080}`); err != nil || len(changes) != 1 {
+		t.Errorf("single change: %v, %+v", err, changes)
+	}
+	for _, bad := range []string{"", "set axios to 1.7", `{"file":"go.mod","op":"set"}`} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) succeeded", bad)
+		}
+	}
+}
+
+const packageJSON = `{
+  "name": "mobile",
+  "scripts": {
+    "test": "jest"
+  },
+  "dependencies": {
+    "expo": "~50.0.0",
+    "socket.io-client": "^4.7.0"
+  },
+  "private": true
+}
+`
+
+func TestEditJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Change
+		want string
+	}{
+		{
+			name: "dotted package name",
+			c:    change("", OpSet, "/dependencies/socket.io-client", `"^4.8.0"`),
+			want: strings.Replace(packageJSON, `"^4.7.0"`, `"^4.8.0"`, 1),
+		},
+		{
+			name: "new key keeps order",
+			c:    change("", OpSet, "/dependencies/axios", `"^1.7.0"`),
+			want: strings.Replace(packageJSON, `"^4.7.0"`, `"^4.7.0",`+"\n    \"axios\": \"^1.7.0\"", 1),
+		},
+		{
+			name: "delete",
+			c:    change("", OpDelete, "/private", ""),
+			want: strings.Replace(packageJSON, ",\n  \"private\": true", "", 1),
+		},
+		{
+			name: "new object",
+			c:    change("", OpSet, "/jest/preset", `"jest-expo"`),
+			want: strings.Replace(packageJSON, "\"private\": true\n", "\"private\": true,\n  \"jest\": {\n    \"preset\": \"jest-expo\"\n  }\n", 1),
+		},
+		{
+			name: "escaped slash",
+			c:    change("", OpSet, "/dependencies/@types~1react", `"18.2.0"`),
+			want: strings.Replace(packageJSON, `"^4.7.0"`, `"^4.7.0",`+"\n    \"@types/react\": \"18.2.0\"", 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyTo(t, "mobile/package.json", packageJSON, tt.c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditJSONErrors(t *testing.T) {
+	for _, c := range []Change{
+		change("", OpSet, "/scripts/test/x", `"y"`),    // "jest" is not an object
+		change("", OpSet, "dependencies.axios", `"1"`), // not a pointer
+		change("", OpDelete, "/dependencies/missing", ""),
+		change("", OpDelete, "/missing/key", ""),
+		change("", OpSet, "/name", `"mobile"`), // no change
+	} {
+		got, err := applyTo(t, "package.json", packageJSON, c)
+		if err == nil {
+			t.Errorf("%s: no error", c)
+		}
+		if got != packageJSON {
+			t.Errorf("%s: file changed to\n%s", c, got)
+		}
+	}
+
+	if _, err := applyTo(t, "tsconfig.json", "{\n  // comment\n}\n", change("", OpSet, "/x", "1")); err == nil {
+		t.Error("JSON with comments was edited")
+	}
+}
+
+const goMod = `module example.com/backend
+
+go 1.21
+
+require (
+	github.com/gofiber/fiber/v2 v2.51.0
+	gorm.io/gorm v1.25.5
+)
+
+require github.com/x/indirect v1.0.0 // indirect
+`
+
+func TestEditGoMod(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Change
+		want string
+	}{
+		{
+			name: "update require",
+			c:    change("", OpSet, "gorm.io/gorm", `"v1.25.7"`),
+			want: strings.Replace(goMod, "v1.25.5", "v1.25.7", 1),
+		},
+		{
+			name: "update keeps comment",
+			c:    change("", OpSet, "github.com/x/indirect", `"v1.1.0"`),
+			want: strings.Replace(goMod, "v1.0.0 // indirect", "v1.1.0 // indirect", 1),
+		},
+		{
+			name: "add require",
+			c:    change("", OpSet, "github.com/google/uuid", `"v1.6.0"`),
+			want: strings.Replace(goMod, "v1.25.5\n", "v1.25.5\n\tgithub.com/google/uuid v1.6.0\n", 1),
+		},
+		{
+			name: "drop require",
+			c:    change("", OpDelete, "github.com/x/indirect", ""),
+			want: strings.Replace(goMod, "require github.com/x/indirect v1.0.0 // indirect\n", "", 1),
+		},
+		{
+			name: "go version",
+			c:    change("", OpSet, "go", `"1.22"`),
+			want: strings.Replace(goMod, "go 1.21", "go 1.22", 1),
+		},
+		{
+			name: "toolchain",
+			c:    change("", OpSet, "toolchain", `"go1.22.3"`),
+			want: strings.Replace(goMod, "go 1.21\n", "go 1.21\ntoolchain go1.22.3\n", 1),
+		},
+		{
+			name: "replace",
+			c:    change("", OpReplace, "gorm.io/gorm", `"../gorm"`),
+			want: goMod + "\nreplace gorm.io/gorm => ../gorm\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyTo(t, "backend/go.mod", goMod, tt.c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditEnvAndTOML(t *testing.T) {
+	env := "DB_HOST=localhost\nPORT=8080\n"
+	if got, err := applyTo(t, ".env.example", env, change("", OpSet, "PORT", "9090")); err != nil || got != "DB_HOST=localhost\nPORT=9090\n" {
+		t.Errorf("env set: %v\n%s", err, got)
+	}
+	if got, err := applyTo(t, ".env.example", env, change("", OpDelete, "DB_HOST", "")); err != nil || got != "PORT=8080\n" {
+		t.Errorf("env delete: %v\n%s", err, got)
+	}
+
+	toml := "title = \"x\"\n\n[build]\ncmd = \"go build\"\n\n[run]\nport = 1\n"
+	got, err := applyTo(t, "air.toml", toml, change("", OpSet, "build.bin", `"./tmp/main"`))
+	if want := "title = \"x\"\n\n[build]\ncmd = \"go build\"\nbin = \"./tmp/main\"\n\n[run]\nport = 1\n"; err != nil || got != want {
+		t.Errorf("toml set: %v\n%s", err, got)
+	}
+	got, err = applyTo(t, "air.toml", toml, change("", OpSet, "log.level", `"debug"`))
+	if want := toml + "\n[log]\nlevel = \"debug\"\n"; err != nil || got != want {
+		t.Errorf("toml new section: %v\n%s", err, got)
+	}
+}
+
+func TestApplyRejects(t *testing.T) {
+	root := t.TempDir()
+	for _, c := range []Change{
+		change("main.go", OpSet, "x", `"1"`),
+		change("../go.mod", OpSet, "go", `"1.22"`),
+		change("missing/package.json", OpSet, "/name", `"x"`),
+	} {
+		if err := Apply(root, c); err == nil {
+			t.Errorf("%s: no error", c)
+		}
+	}
+}
@@ -0,0 +1,446 @@
+package configedit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// editGoMod edits require, replace, go and toolchain directives.
+func editGoMod(data []byte, c Change) ([]byte, error) {
+	lines := strings.Split(string(data), "\n")
+
+	if c.Key == "go" || c.Key == "toolchain" {
+		if c.Op != OpSet {
+			return nil, fmt.Errorf("go.mod: only set is supported for %s", c.Key)
+		}
+		value, err := stringValue(c.Value)
+		if err != nil {
+			return nil, err
+		}
+		for i, l := range lines {
+			if fields := strings.Fields(l); len(fields) == 2 && fields[0] == c.Key {
+				lines[i] = c.Key + " " + value
+				return []byte(strings.Join(lines, "\n")), nil
+			}
+		}
+		after := "module"
+		if c.Key == "toolchain" {
+			after = "go"
+		}
+		return []byte(insertAfter(lines, after, c.Key+" "+value)), nil
+	}
+
+	directive := "require"
+	if c.Op == OpReplace || c.Op == OpDropReplace {
+		directive = "replace"
+	}
+
+	// Find existing entries for the module, in blocks or single-line.
+	var found []int
+	firstBlockEnd := -1
+	inBlock := false
+	for i, l := range lines {
+		trimmed := strings.TrimSpace(l)
+		switch {
+		case trimmed == directive+" (":
+			inBlock = true
+		case inBlock && trimmed == ")":
+			inBlock = false
+			if firstBlockEnd < 0 {
+				firstBlockEnd = i
+			}
+		case inBlock && firstField(trimmed) == c.Key:
+			found = append(found, i)
+		case strings.HasPrefix(trimmed, directive+" ") && firstField(strings.TrimPrefix(trimmed, directive+" ")) == c.Key:
+			found = append(found, i)
+		}
+	}
+
+	var entry string
+	switch c.Op {
+	case OpSet:
+		version, err := stringValue(c.Value)
+		if err != nil {
+			return nil, err
+		}
+		entry = c.Key + " " + version
+	case OpReplace:
+		target, err := stringValue(c.Value)
+		if err != nil {
+			return nil, err
+		}
+		entry = c.Key + " => " + target
+	case OpDelete, OpDropReplace:
+		if len(found) == 0 {
+			return nil, fmt.Errorf("go.mod has no %s for %s", directive, c.Key)
+		}
+		for i := len(found) - 1; i >= 0; i-- {
+			at := found[i]
+			lines = append(lines[:at], lines[at+1:]...)
+			// Drop a block left empty, with the blank line before it.
+			if at > 0 && at < len(lines) && strings.TrimSpace(lines[at-1]) == directive+" (" && strings.TrimSpace(lines[at]) == ")" {
+				start := at - 1
+				if start > 0 && strings.TrimSpace(lines[start-1]) == "" {
+					start--
+				}
+				lines = append(lines[:start], lines[at+1:]...)
+			}
+		}
+		return []byte(strings.Join(lines, "\n")), nil
+	default:
+		return nil, fmt.Errorf("go.mod: unknown op %q", c.Op)
+	}
+
+	if len(found) > 0 {
+		i := found[0]
+		prefix := "\t"
+		if !strings.HasPrefix(lines[i], "\t") {
+			prefix = directive + " "
+		}
+		comment := ""
+		if at := strings.Index(lines[i], "//"); at >= 0 && c.Op == OpSet {
+			comment = " " + lines[i][at:]
+		}
+		lines[i] = prefix + entry + comment
+		return []byte(strings.Join(lines, "\n")), nil
+	}
+	if firstBlockEnd >= 0 {
+		lines = append(lines[:firstBlockEnd], append([]string{"\t" + entry}, lines[firstBlockEnd:]...)...)
+		return []byte(strings.Join(lines, "\n")), nil
+	}
+	text := strings.TrimRight(strings.Join(lines, "\n"), "\n")
+	return []byte(text + "\n\n" + directive + " " + entry + "\n"), nil
+}
+
+func firstField(s string) string {
+	if fields := strings.Fields(s); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// insertAfter adds a line after the first one starting with the directive
+// name, or after the module line.
+func insertAfter(lines []string, name, line string) string {
+	at := -1
+	for i, l := range lines {
+		if first := firstField(l); first == name || at < 0 && first == "module" {
+			at = i
+			if first == name {
+				break
+			}
+		}
+	}
+	if name == "go" && at >= 0 && firstField(lines[at]) == "go" {
+		lines = append(lines[:at+1], append([]string{line}, lines[at+1:]...)...)
+	} else if at >= 0 {
+		lines = append(lines[:at+1], append([]string{"", line}, lines[at+1:]...)...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// editEnv sets or deletes a KEY=value line.
+func editEnv(data []byte, c Change) ([]byte, error) {
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	index := -1
+	for i, l := range lines {
+		name, _, ok := strings.Cut(strings.TrimPrefix(strings.TrimSpace(l), "export "), "=")
+		if ok && strings.TrimSpace(name) == c.Key {
+			index = i
+			break
+		}
+	}
+
+	switch c.Op {
+	case OpSet:
+		value, err := stringValue(c.Value)
+		if err != nil {
+			return nil, err
+		}
+		if index >= 0 {
+			lines[index] = c.Key + "=" + value
+		} else {
+			lines = append(lines, c.Key+"="+value)
+		}
+	case OpDelete:
+		if index < 0 {
+			return nil, fmt.Errorf("%s is not set", c.Key)
+		}
+		lines = append(lines[:index], lines[index+1:]...)
+	default:
+		return nil, fmt.Errorf(".env: unknown op %q", c.Op)
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// editTOML sets or deletes "key = value" in a [section]; the last dotted
+// part of the key is the key, the rest the section.
+func editTOML(data []byte, c Change) ([]byte, error) {
+	section, key := "", c.Key
+	if dot := strings.LastIndexByte(c.Key, '.'); dot >= 0 {
+		section, key = c.Key[:dot], c.Key[dot+1:]
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	current := ""
+	found := section == "" // top-level keys come before any [section]
+	index, insertAt := -1, 0
+	for i, l := range lines {
+		trimmed := strings.TrimSpace(l)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			current = strings.Trim(trimmed, "[] ")
+			if current == section {
+				found, insertAt = true, i+1
+			}
+			continue
+		}
+		if current != section {
+			continue
+		}
+		if name, _, ok := strings.Cut(trimmed, "="); ok && !strings.HasPrefix(trimmed, "#") {
+			insertAt = i + 1
+			if strings.TrimSpace(name) == key {
+				index = i
+			}
+		}
+	}
+
+	switch c.Op {
+	case OpSet:
+		value := bytes.TrimSpace(c.Value)
+		if len(value) == 0 || value[0] == '{' {
+			return nil, fmt.Errorf("toml: value must be a string, number, bool or array")
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, value); err != nil {
+			return nil, fmt.Errorf("toml: %w", err)
+		}
+		entry := key + " = " + compact.String()
+		switch {
+		case index >= 0:
+			lines[index] = entry
+		case !found:
+			lines = append(lines, "", "["+section+"]", entry)
+		default:
+			lines = append(lines[:insertAt], append([]string{entry}, lines[insertAt:]...)...)
+		}
+	case OpDelete:
+		if index < 0 {
+			return nil, fmt.Errorf("%s is not set", c.Key)
+		}
+		lines = append(lines[:index], lines[index+1:]...)
+	default:
+		return nil, fmt.Errorf("toml: unknown op %q", c.Op)
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// editJSON sets or deletes the value at a JSON pointer, keeping key order
+// and indentation. Objects missing along the path are created.
+func editJSON(data []byte, c Change) ([]byte, error) {
+	path, err := splitPointer(c.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := decodeOrdered(dec)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s (comments are not supported): %w", c.File, err)
+	}
+	obj, ok := root.(*object)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a JSON object", c.File)
+	}
+
+	for i, name := range path[:len(path)-1] {
+		value, exists := obj.values[name]
+		if !exists {
+			if c.Op == OpDelete {
+				return nil, fmt.Errorf("%s is not set", c.Key)
+			}
+			next := &object{}
+			obj.set(name, next)
+			obj = next
+			continue
+		}
+		next, ok := value.(*object)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an object", "/"+strings.Join(path[:i+1], "/"))
+		}
+		obj = next
+	}
+	last := path[len(path)-1]
+
+	switch c.Op {
+	case OpSet:
+		vdec := json.NewDecoder(bytes.NewReader(c.Value))
+		vdec.UseNumber()
+		value, err := decodeOrdered(vdec)
+		if err != nil {
+			return nil, fmt.Errorf("value: %w", err)
+		}
+		obj.set(last, value)
+	case OpDelete:
+		if !obj.remove(last) {
+			return nil, fmt.Errorf("%s is not set", c.Key)
+		}
+	default:
+		return nil, fmt.Errorf("json: unknown op %q", c.Op)
+	}
+
+	var out bytes.Buffer
+	encodeOrdered(&out, root, detectIndent(data), "")
+	if bytes.HasSuffix(data, []byte("\n")) {
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// splitPointer splits a JSON pointer such as "/dependencies/socket.io-client"
+// into its keys, undoing the ~1 (/) and ~0 (~) escapes.
+func splitPointer(pointer string) ([]string, error) {
+	if !strings.HasPrefix(pointer, "/") || pointer == "/" {
+		return nil, fmt.Errorf("JSON key %q must be a JSON pointer such as /dependencies/axios", pointer)
+	}
+	path := strings.Split(pointer[1:], "/")
+	for i, p := range path {
+		path[i] = strings.ReplaceAll(strings.ReplaceAll(p, "~1", "/"), "~0", "~")
+	}
+	return path, nil
+}
+
+// object is a JSON object that remembers its key order.
+type object struct {
+	keys   []string
+	values map[string]any
+}
+
+func (o *object) set(key string, value any) {
+	if o.values == nil {
+		o.values = make(map[string]any)
+	}
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *object) remove(key string) bool {
+	if _, ok := o.values[key]; !ok {
+		return false
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func decodeOrdered(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := &object{}
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj.set(keyTok.(string), value)
+			}
+			_, err := dec.Token()
+			return obj, err
+		case '[':
+			list := []any{}
+			for dec.More() {
+				value, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, value)
+			}
+			_, err := dec.Token()
+			return list, err
+		}
+		return nil, fmt.Errorf("unexpected %v", t)
+	default:
+		return t, nil
+	}
+}
+
+func encodeOrdered(w *bytes.Buffer, v any, indent, prefix string) {
+	inner := prefix + indent
+	switch t := v.(type) {
+	case *object:
+		if len(t.keys) == 0 {
+			w.WriteString("{}")
+			return
+		}
+		w.WriteString("{\n")
+		for i, k := range t.keys {
+			w.WriteString(inner)
+			writeScalar(w, k)
+			w.WriteString(": ")
+			encodeOrdered(w, t.values[k], indent, inner)
+			if i < len(t.keys)-1 {
+				w.WriteByte(',')
+			}
+			w.WriteByte('\n')
+		}
+		w.WriteString(prefix + "}")
+	case []any:
+		if len(t) == 0 {
+			w.WriteString("[]")
+			return
+		}
+		w.WriteString("[\n")
+		for i, item := range t {
+			w.WriteString(inner)
+			encodeOrdered(w, item, indent, inner)
+			if i < len(t)-1 {
+				w.WriteByte(',')
+			}
+			w.WriteByte('\n')
+		}
+		w.WriteString(prefix + "]")
+	default:
+		writeScalar(w, t)
+	}
+}
+
+func writeScalar(w io.Writer, v any) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	w.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
+
+// detectIndent returns the indentation of the first indented line, or two
+// spaces.
+func detectIndent(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if len(trimmed) < len(line) && trimmed != "" {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
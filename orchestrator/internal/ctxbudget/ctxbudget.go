@@ -0,0 +1,244 @@
+// Package ctxbudget keeps prompts inside a model's context window. It
+// estimates token counts, dedupes repeated compiler errors, head/tail-samples
+// long logs, summarizes stale output and reports everything it dropped.
+package ctxbudget
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// charsPerToken is the rough ratio used for estimates. It errs on the side of
+// overestimating for code and logs.
+const charsPerToken = 3.5
+
+// EstimateTokens returns an approximate token count for s.
+func EstimateTokens(s string) int {
+	if s == "" {
+		return 0
+	}
+	return int(float64(len(s))/charsPerToken) + 1
+}
+
+func tokensToChars(tokens int) int {
+	return int(float64(tokens) * charsPerToken)
+}
+
+// Kind selects how a section is shrunk.
+type Kind int
+
+const (
+	// Log is tool output: deduped first, then head/tail-sampled.
+	Log Kind = iota
+	// Stale is older output that is summarized down to its salient lines.
+	Stale
+	// Prose is truncated from the end.
+	Prose
+)
+
+// Section is one variable-size part of a prompt.
+type Section struct {
+	Name      string
+	Text      string
+	Kind      Kind
+	Priority  int // higher priorities are shrunk last
+	MinTokens int // never shrink below this unless nothing else is left
+}
+
+// Drop records what was removed from a section.
+type Drop struct {
+	Section string
+	Tokens  int
+	How     string
+}
+
+// Report describes the outcome of Fit.
+type Report struct {
+	Limit   int
+	Before  int
+	After   int
+	Dropped []Drop
+}
+
+func (r Report) String() string {
+	if len(r.Dropped) == 0 {
+		return fmt.Sprintf("%d/%d tokens, nothing dropped", r.After, r.Limit)
+	}
+	parts := make([]string, len(r.Dropped))
+	for i, d := range r.Dropped {
+		parts[i] = fmt.Sprintf("%s -%d (%s)", d.Section, d.Tokens, d.How)
+	}
+	return fmt.Sprintf("%d→%d/%d tokens, dropped: %s", r.Before, r.After, r.Limit, strings.Join(parts, ", "))
+}
+
+// Fit shrinks sections in place until their total fits limit tokens.
+func Fit(sections []*Section, limit int) Report {
+	report := Report{Limit: limit, Before: total(sections)}
+
+	shrink := func(s *Section, text, how string) {
+		if before, after := EstimateTokens(s.Text), EstimateTokens(text); after < before {
+			report.Dropped = append(report.Dropped, Drop{Section: s.Name, Tokens: before - after, How: how})
+			s.Text = text
+		}
+	}
+
+	// Cheap, lossless-ish passes first.
+	if total(sections) > limit {
+		for _, s := range sections {
+			if s.Kind == Log {
+				deduped, n := DedupeErrors(s.Text, 3)
+				if n > 0 {
+					shrink(s, deduped, fmt.Sprintf("%d repeated errors", n))
+				}
+			}
+		}
+	}
+
+	order := make([]*Section, len(sections))
+	copy(order, sections)
+	sort.SliceStable(order, func(i, j int) bool { return order[i].Priority < order[j].Priority })
+
+	// Shrink lowest priority first, respecting minimums.
+	for _, s := range order {
+		over := total(sections) - limit
+		if over <= 0 {
+			break
+		}
+		target := max(EstimateTokens(s.Text)-over, s.MinTokens)
+		if target >= EstimateTokens(s.Text) {
+			continue
+		}
+		text, how := reduce(s, target)
+		shrink(s, text, how)
+	}
+
+	// Still over: ignore minimums.
+	for _, s := range order {
+		over := total(sections) - limit
+		if over <= 0 {
+			break
+		}
+		target := max(EstimateTokens(s.Text)-over, 0)
+		text, how := reduce(s, target)
+		shrink(s, text, how)
+	}
+
+	report.After = total(sections)
+	return report
+}
+
+func reduce(s *Section, targetTokens int) (string, string) {
+	switch s.Kind {
+	case Stale:
+		return Summarize(s.Text, targetTokens), "summarized"
+	case Log:
+		return HeadTail(s.Text, targetTokens), "head/tail"
+	default:
+		return Truncate(s.Text, targetTokens), "truncated"
+	}
+}
+
+func total(sections []*Section) int {
+	n := 0
+	for _, s := range sections {
+		n += EstimateTokens(s.Text)
+	}
+	return n
+}
+
+// locationPrefix matches "path/file.go:12:5: " and "file.ts(12,5): " prefixes.
+var locationPrefix = regexp.MustCompile(`^\S+?(:\d+(:\d+)?:|\(\d+,\d+\):)\s*`)
+
+// DedupeErrors keeps at most keep occurrences of each error message,
+// ignoring the file position, and returns how many lines were removed.
+func DedupeErrors(s string, keep int) (string, int) {
+	lines := strings.Split(s, "\n")
+	seen := make(map[string]int)
+	out := make([]string, 0, len(lines))
+	removed := 0
+
+	for _, line := range lines {
+		key := strings.TrimSpace(locationPrefix.ReplaceAllString(line, ""))
+		if key == "" {
+			out = append(out, line)
+			continue
+		}
+		seen[key]++
+		if seen[key] > keep {
+			removed++
+			continue
+		}
+		out = append(out, line)
+	}
+
+	if removed > 0 {
+		out = append(out, fmt.Sprintf("... (%d repeated lines removed)", removed))
+	}
+	return strings.Join(out, "\n"), removed
+}
+
+// HeadTail keeps the start and end of s within maxTokens, giving the tail,
+// where build failures usually end up, the larger share.
+func HeadTail(s string, maxTokens int) string {
+	maxChars := tokensToChars(maxTokens)
+	if len(s) <= maxChars {
+		return s
+	}
+
+	const marker = "\n... (%d chars omitted) ...\n"
+	budget := maxChars - len(marker) - 8
+	if budget <= 0 {
+		return ""
+	}
+	head := budget / 3
+	tail := budget - head
+
+	headPart := s[:head]
+	if i := strings.LastIndexByte(headPart, '\n'); i > 0 {
+		headPart = headPart[:i]
+	}
+	tailPart := s[len(s)-tail:]
+	if i := strings.IndexByte(tailPart, '\n'); i >= 0 && i < len(tailPart)-1 {
+		tailPart = tailPart[i+1:]
+	}
+
+	return headPart + fmt.Sprintf(marker, len(s)-len(headPart)-len(tailPart)) + tailPart
+}
+
+// Truncate cuts s to maxTokens.
+func Truncate(s string, maxTokens int) string {
+	maxChars := tokensToChars(maxTokens)
+	if len(s) <= maxChars {
+		return s
+	}
+	const marker = "\n... (truncated)"
+	if maxChars <= len(marker) {
+		return ""
+	}
+	return s[:maxChars-len(marker)] + marker
+}
+
+// salient matches lines worth keeping when summarizing: errors, failures and
+// file paths.
+var salient = regexp.MustCompile(`(?i)(error|fail|panic|warning|created|modified|updated|deleted|\.(go|ts|tsx|js|json|mod)\b)`)
+
+// Summarize reduces stale output to its salient lines, falling back to
+// head/tail sampling if those alone are still too long.
+func Summarize(s string, maxTokens int) string {
+	if EstimateTokens(s) <= maxTokens {
+		return s
+	}
+
+	var kept []string
+	lines := strings.Split(s, "\n")
+	for _, line := range lines {
+		if salient.MatchString(line) {
+			kept = append(kept, strings.TrimSpace(line))
+		}
+	}
+
+	summary := fmt.Sprintf("[summary of %d lines]\n%s", len(lines), strings.Join(kept, "\n"))
+	return HeadTail(summary, maxTokens)
+}
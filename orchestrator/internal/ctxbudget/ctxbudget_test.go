@@ -0,0 +1,208 @@
+package ctxbudget
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"abc", 1},
+		{"abcd", 2},
+		{strings.Repeat("x", 350), 101},
+	}
+
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.in); got != tt.want {
+			t.Errorf("EstimateTokens(%d chars) = %d, want %d", len(tt.in), got, tt.want)
+		}
+	}
+}
+
+// numbered returns n lines "line 0" ... "line n-1".
+func numbered(n int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestHeadTail(t *testing.T) {
+	long := numbered(1000)
+	tests := []struct {
+		name      string
+		in        string
+		maxTokens int
+		sampled   bool // check the head/tail shape instead of want
+		want      string
+	}{
+		{"fits", "short log", 10, false, "short log"},
+		{"no room for the marker", long, 5, false, ""},
+		{"sampled", long, 200, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HeadTail(tt.in, tt.maxTokens)
+			if !tt.sampled {
+				if got != tt.want {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+				return
+			}
+
+			if len(got) > tokensToChars(tt.maxTokens) {
+				t.Errorf("output is %d chars, over the %d char budget", len(got), tokensToChars(tt.maxTokens))
+			}
+			head, tail, ok := strings.Cut(got, "\n... (")
+			if !ok {
+				t.Fatalf("no omission marker in %q", got)
+			}
+			_, tail, _ = strings.Cut(tail, " chars omitted) ...\n")
+			if !strings.HasPrefix(tt.in, head) || !strings.HasSuffix(tt.in, tail) {
+				t.Errorf("head/tail are not the input's ends: %q ... %q", head, tail)
+			}
+			if !strings.HasPrefix(tail, "line ") || strings.HasSuffix(head, "\n") {
+				t.Errorf("cut mid-line: %q ... %q", head, tail)
+			}
+			if len(tail) <= len(head) {
+				t.Errorf("tail (%d chars) should be longer than head (%d chars)", len(tail), len(head))
+			}
+			omitted := len(tt.in) - len(head) - len(tail)
+			if !strings.Contains(got, fmt.Sprintf("(%d chars omitted)", omitted)) {
+				t.Errorf("marker does not report %d omitted chars", omitted)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	tests := []struct {
+		in        string
+		maxTokens int
+		want      string
+	}{
+		{"short", 10, "short"},
+		{long, 100, long},
+		{long, 4, ""}, // 14 chars leave no room for the marker
+		{long, 10, strings.Repeat("x", 19) + "\n... (truncated)"},
+	}
+
+	for _, tt := range tests {
+		got := Truncate(tt.in, tt.maxTokens)
+		if got != tt.want {
+			t.Errorf("Truncate(%d chars, %d) = %q, want %q", len(tt.in), tt.maxTokens, got, tt.want)
+		}
+		if len(got) > tokensToChars(tt.maxTokens) {
+			t.Errorf("Truncate(%d chars, %d) is %d chars, over budget", len(tt.in), tt.maxTokens, len(got))
+		}
+	}
+}
+
+func TestDedupeErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		in          string
+		keep        int
+		want        string
+		wantRemoved int
+	}{
+		{
+			name:        "go positions",
+			in:          "a.go:1:2: undefined: x\nb.go:3:4: undefined: x\nc.go:5:6: undefined: x\nd.go:7: other",
+			keep:        2,
+			want:        "a.go:1:2: undefined: x\nb.go:3:4: undefined: x\nd.go:7: other\n... (1 repeated lines removed)",
+			wantRemoved: 1,
+		},
+		{
+			name:        "typescript positions",
+			in:          "a.ts(1,2): error TS2304\nb.ts(3,4): error TS2304",
+			keep:        1,
+			want:        "a.ts(1,2): error TS2304\n... (1 repeated lines removed)",
+			wantRemoved: 1,
+		},
+		{
+			name: "blank lines are kept",
+			in:   "x\n\n\n\nx",
+			keep: 3,
+			want: "x\n\n\n\nx",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, removed := DedupeErrors(tt.in, tt.keep)
+			if got != tt.want || removed != tt.wantRemoved {
+				t.Errorf("got %q (%d removed), want %q (%d removed)", got, removed, tt.want, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var b strings.Builder
+	for i := range 200 {
+		fmt.Fprintf(&b, "compiling step %d\n", i)
+	}
+	b.WriteString("  main.go:3: error: boom\n")
+
+	got := Summarize(b.String(), 50)
+	want := "[summary of 202 lines]\nmain.go:3: error: boom"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if short := "ok"; Summarize(short, 50) != short {
+		t.Error("short input was changed")
+	}
+}
+
+func TestFit(t *testing.T) {
+	prose := strings.Repeat("p", 350)  // 101 tokens
+	logs := numbered(200)              // ~430 tokens
+	stale := strings.Repeat("s", 1400) // 401 tokens
+
+	tests := []struct {
+		name      string
+		limit     int
+		wantDrops []string // sections shrunk, in order
+	}{
+		{"fits", 2000, nil},
+		{"lowest priority first", 600, []string{"stale"}},
+		{"minimums respected while possible", 300, []string{"stale", "log"}},
+		// Prose has no minimum, so it goes before the others lose theirs.
+		{"minimums ignored when over", 20, []string{"stale", "log", "prose", "stale", "log"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sections := []*Section{
+				{Name: "prose", Text: prose, Kind: Prose, Priority: 3},
+				{Name: "log", Text: logs, Kind: Log, Priority: 2, MinTokens: 100},
+				{Name: "stale", Text: stale, Kind: Stale, Priority: 1, MinTokens: 50},
+			}
+			report := Fit(sections, tt.limit)
+
+			var drops []string
+			for _, d := range report.Dropped {
+				drops = append(drops, d.Section)
+			}
+			if strings.Join(drops, ",") != strings.Join(tt.wantDrops, ",") {
+				t.Errorf("dropped from %v, want %v (%s)", drops, tt.wantDrops, report)
+			}
+			if report.After > tt.limit {
+				t.Errorf("after = %d, over the %d limit", report.After, tt.limit)
+			}
+			if report.After != total(sections) || report.Before != EstimateTokens(prose)+EstimateTokens(logs)+EstimateTokens(stale) {
+				t.Errorf("report totals are off: %+v", report)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// Package diag parses compiler and vet output into structured diagnostics
+// and renders the source lines around them.
+package diag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/patch"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/retrieval"
+)
+
+// Diagnostic is one compiler or vet message.
+type Diagnostic struct {
+	Source   string // "go" or "tsc"
+	File     string // as printed, relative to the command's directory
+	Line     int
+	Column   int // 0 when not reported
+	Severity string
+	Code     string // e.g. TS2304; empty for Go
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	loc := fmt.Sprintf("%s:%d", d.File, d.Line)
+	if d.Column > 0 {
+		loc += fmt.Sprintf(":%d", d.Column)
+	}
+	if d.Code != "" {
+		return fmt.Sprintf("%s: %s %s: %s", loc, d.Severity, d.Code, d.Message)
+	}
+	return fmt.Sprintf("%s: %s", loc, d.Message)
+}
+
+var (
+	ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+	// ./internal/x.go:12:5: undefined: foo (column optional, as in go test)
+	goRe = regexp.MustCompile(`^(?:vet: )?(\S+\.go):(\d+)(?::(\d+))?: (.+)$`)
+	// src/app.tsx(12,5): error TS2304: Cannot find name 'foo'.
+	tscRe = regexp.MustCompile(`^(\S+\.[cm]?[jt]sx?)\((\d+),(\d+)\): (error|warning) (TS\d+): (.+)$`)
+	// src/app.tsx:12:5 - error TS2304: Cannot find name 'foo'.  (--pretty)
+	tscPrettyRe = regexp.MustCompile(`^(\S+\.[cm]?[jt]sx?):(\d+):(\d+) - (error|warning) (TS\d+): (.+)$`)
+)
+
+// Parse extracts Go and tsc diagnostics from command output. Indented lines
+// following a diagnostic, such as Go's have/want or tsc's elaborations, are
+// appended to its message; tsc's pretty code frames are dropped.
+func Parse(output string) []Diagnostic {
+	var diags []Diagnostic
+	var last *Diagnostic
+	for _, raw := range strings.Split(ansiRe.ReplaceAllString(output, ""), "\n") {
+		line := strings.TrimRight(raw, "\r ")
+
+		if d, ok := parseLine(line); ok {
+			diags = append(diags, d)
+			last = &diags[len(diags)-1]
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if last != nil && trimmed != "" && (strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "  ")) && !isCodeFrame(trimmed) {
+			last.Message += "\n" + trimmed
+			continue
+		}
+		last = nil
+	}
+	return diags
+}
+
+func parseLine(line string) (Diagnostic, bool) {
+	if m := tscRe.FindStringSubmatch(line); m != nil {
+		return tscDiagnostic(m), true
+	}
+	if m := tscPrettyRe.FindStringSubmatch(line); m != nil {
+		return tscDiagnostic(m), true
+	}
+	if m := goRe.FindStringSubmatch(line); m != nil {
+		lineNo, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		return Diagnostic{
+			Source:   "go",
+			File:     strings.TrimPrefix(m[1], "./"),
+			Line:     lineNo,
+			Column:   col,
+			Severity: "error",
+			Message:  m[4],
+		}, true
+	}
+	return Diagnostic{}, false
+}
+
+func tscDiagnostic(m []string) Diagnostic {
+	lineNo, _ := strconv.Atoi(m[2])
+	col, _ := strconv.Atoi(m[3])
+	return Diagnostic{
+		Source:   "tsc",
+		File:     m[1],
+		Line:     lineNo,
+		Column:   col,
+		Severity: m[4],
+		Code:     m[5],
+		Message:  m[6],
+	}
+}
+
+// isCodeFrame matches the "12 const x = foo;" and "~~~" lines tsc --pretty
+// prints under a diagnostic.
+var codeFrameRe = regexp.MustCompile(`^(\d+\s|~+$|\s*$)`)
+
+func isCodeFrame(line string) bool {
+	return codeFrameRe.MatchString(line)
+}
+
+// Count returns the number of errors and warnings in diags.
+func Count(diags []Diagnostic) (errs, warnings int) {
+	for _, d := range diags {
+		if d.Severity == "warning" {
+			warnings++
+		} else {
+			errs++
+		}
+	}
+	return errs, warnings
+}
+
+var cdRe = regexp.MustCompile(`^\s*cd\s+(\S+)\s*&&`)
+
+// CommandDir returns the directory a shell command like "cd backend && go
+// build ./..." runs in, relative to root; diagnostic paths are relative to it.
+func CommandDir(root, command string) string {
+	if m := cdRe.FindStringSubmatch(command); m != nil {
+		if filepath.IsAbs(m[1]) {
+			return m[1]
+		}
+		return filepath.Join(root, m[1])
+	}
+	return root
+}
+
+// Excerpt renders up to radius lines either side of a diagnostic, marking
+// its line. File paths are resolved against dir and must stay below it. It
+// returns "" when the file is outside dir, may hold secrets or can't be read.
+func Excerpt(dir string, d Diagnostic, radius int) string {
+	path, err := patch.ResolveInRoot(dir, d.File)
+	if err != nil || retrieval.IsSecret(filepath.Base(path)) {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	lines := strings.Split(string(data), "\n")
+	if d.Line < 1 || d.Line > len(lines) {
+		return ""
+	}
+	from, to := max(d.Line-radius, 1), min(d.Line+radius, len(lines))
+
+	var b strings.Builder
+	for n := from; n <= to; n++ {
+		marker := " "
+		if n == d.Line {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%s %4d | %s\n", marker, n, lines[n-1])
+	}
+	return b.String()
+}
+
+// Render lists up to limit diagnostics (0 is unlimited), each followed by
+// the source around it. Diagnostics on an already-shown line are listed
+// without repeating the excerpt.
+func Render(dir string, diags []Diagnostic, radius, limit int) string {
+	var b strings.Builder
+	shown := make(map[string]bool)
+	for i, d := range diags {
+		if limit > 0 && i == limit {
+			fmt.Fprintf(&b, "... %d more diagnostics\n", len(diags)-limit)
+			break
+		}
+		b.WriteString(d.String() + "\n")
+
+		key := fmt.Sprintf("%s:%d", d.File, d.Line)
+		if shown[key] {
+			continue
+		}
+		shown[key] = true
+		if excerpt := Excerpt(dir, d, radius); excerpt != "" {
+			b.WriteString(excerpt)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
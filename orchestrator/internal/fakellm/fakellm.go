@@ -0,0 +1,368 @@
+// Package fakellm is a local chat-completions server for running the
+// orchestrator without a live LLM API. It can serve scripted responses,
+// record real request/response pairs to fixture files, and replay them.
+package fakellm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/agents"
+)
+
+// Mode selects how the server answers requests.
+type Mode string
+
+const (
+	ModeScript Mode = "script" // answer from the scripted queue
+	ModeRecord Mode = "record" // proxy to Upstream and save fixtures
+	ModeReplay Mode = "replay" // answer from saved fixtures
+)
+
+// Config configures a Server.
+type Config struct {
+	Mode        Mode
+	FixtureDir  string // where fixtures are read from and written to
+	UpstreamURL string // real chat completions endpoint (record mode)
+	APIKey      string // upstream API key; falls back to the client's header
+}
+
+// Response is a scripted reply.
+type Response struct {
+	Content   string
+	ToolCalls []agents.ToolCall
+	Usage     agents.Usage
+	Status    int    // non-200 statuses return Body as an error
+	Body      string // raw error body
+}
+
+// Fixture is a recorded request/response pair stored as JSON.
+type Fixture struct {
+	Hash     string             `json:"hash"`
+	Request  agents.ChatRequest `json:"request"`
+	Status   int                `json:"status"`
+	Response json.RawMessage    `json:"response"`
+}
+
+// Server is an http.Handler speaking the chat completions protocol.
+type Server struct {
+	cfg    Config
+	client *http.Client
+
+	mu       sync.Mutex
+	script   []Response
+	fixtures map[string]Fixture
+	requests []agents.ChatRequest
+}
+
+// New creates a server. In replay mode all fixtures are loaded up front.
+func New(cfg Config) (*Server, error) {
+	if cfg.Mode == "" {
+		cfg.Mode = ModeScript
+	}
+
+	s := &Server{
+		cfg:      cfg,
+		client:   &http.Client{},
+		fixtures: make(map[string]Fixture),
+	}
+
+	switch cfg.Mode {
+	case ModeScript:
+	case ModeRecord:
+		if cfg.UpstreamURL == "" {
+			return nil, fmt.Errorf("record mode requires an upstream URL")
+		}
+		if err := os.MkdirAll(cfg.FixtureDir, 0755); err != nil {
+			return nil, fmt.Errorf("create fixture dir: %w", err)
+		}
+	case ModeReplay:
+		if err := s.loadFixtures(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown mode %q", cfg.Mode)
+	}
+
+	return s, nil
+}
+
+// NewScripted returns a script-mode server queued with replies.
+func NewScripted(replies ...string) *Server {
+	s, _ := New(Config{Mode: ModeScript})
+	for _, r := range replies {
+		s.Push(Response{Content: r})
+	}
+	return s
+}
+
+// Start serves s on a local httptest server. The caller must Close it.
+func (s *Server) Start() *httptest.Server {
+	return httptest.NewServer(s)
+}
+
+// Push appends responses to the script.
+func (s *Server) Push(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append(s.script, responses...)
+}
+
+// Requests returns every request the server has received.
+func (s *Server) Requests() []agents.ChatRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]agents.ChatRequest(nil), s.requests...)
+}
+
+// PromptHash identifies a request by its conversation alone: roles, contents
+// with whitespace collapsed, tool call names and offered tools. Sampling
+// parameters and the stream flag are ignored.
+func PromptHash(req agents.ChatRequest) string {
+	h := sha256.New()
+	for _, m := range req.Messages {
+		fmt.Fprintf(h, "%s\x00%s\x00", m.Role, strings.Join(strings.Fields(m.Content), " "))
+		for _, call := range m.ToolCalls {
+			fmt.Fprintf(h, "call:%s\x00", call.Function.Name)
+		}
+	}
+	for _, t := range req.Tools {
+		fmt.Fprintf(h, "tool:%s\x00", t.Function.Name)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "read body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req agents.ChatRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	var status int
+	var respBody []byte
+	switch s.cfg.Mode {
+	case ModeScript:
+		status, respBody = s.scripted(req)
+	case ModeReplay:
+		status, respBody = s.replay(req)
+	case ModeRecord:
+		status, respBody, err = s.record(r, req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	if status != http.StatusOK || !req.Stream {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(respBody)
+		return
+	}
+
+	var resp agents.ChatResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		http.Error(w, "invalid stored response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeStream(w, &resp)
+}
+
+func (s *Server) scripted(req agents.ChatRequest) (int, []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.script) == 0 {
+		return http.StatusInternalServerError, errorBody("fakellm: no scripted response left")
+	}
+	next := s.script[0]
+	s.script = s.script[1:]
+
+	if next.Status != 0 && next.Status != http.StatusOK {
+		return next.Status, []byte(next.Body)
+	}
+
+	finish := "stop"
+	if len(next.ToolCalls) > 0 {
+		finish = "tool_calls"
+	}
+	return http.StatusOK, mustJSON(agents.ChatResponse{
+		Model: orDefault(req.Model, "fakellm"),
+		Choices: []agents.ChatChoice{{
+			Message:      agents.ChatMessage{Role: "assistant", Content: next.Content, ToolCalls: next.ToolCalls},
+			FinishReason: finish,
+		}},
+		Usage: next.Usage,
+	})
+}
+
+func (s *Server) replay(req agents.ChatRequest) (int, []byte) {
+	hash := PromptHash(req)
+
+	s.mu.Lock()
+	fx, ok := s.fixtures[hash]
+	s.mu.Unlock()
+
+	if !ok {
+		return http.StatusNotFound, errorBody("fakellm: no fixture for prompt hash " + hash)
+	}
+	return fx.Status, fx.Response
+}
+
+// record forwards the request upstream without streaming and saves the pair.
+func (s *Server) record(r *http.Request, req agents.ChatRequest) (int, []byte, error) {
+	upstreamReq := req
+	upstreamReq.Stream = false
+	upstreamReq.StreamOptions = nil
+
+	body, err := json.Marshal(upstreamReq)
+	if err != nil {
+		return 0, nil, fmt.Errorf("marshal upstream request: %w", err)
+	}
+
+	out, err := http.NewRequestWithContext(r.Context(), http.MethodPost, s.cfg.UpstreamURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, fmt.Errorf("create upstream request: %w", err)
+	}
+	out.Header.Set("Content-Type", "application/json")
+	if s.cfg.APIKey != "" {
+		out.Header.Set("Authorization", "Bearer "+s.cfg.APIKey)
+	} else if auth := r.Header.Get("Authorization"); auth != "" {
+		out.Header.Set("Authorization", auth)
+	}
+
+	resp, err := s.client.Do(out)
+	if err != nil {
+		return 0, nil, fmt.Errorf("upstream call failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("read upstream response: %w", err)
+	}
+
+	fx := Fixture{
+		Hash:     PromptHash(req),
+		Request:  upstreamReq,
+		Status:   resp.StatusCode,
+		Response: json.RawMessage(respBody),
+	}
+	if !json.Valid(respBody) {
+		fx.Response = mustJSON(string(respBody))
+	}
+	if err := s.saveFixture(fx); err != nil {
+		return 0, nil, err
+	}
+
+	return resp.StatusCode, respBody, nil
+}
+
+func (s *Server) saveFixture(fx Fixture) error {
+	data, err := json.MarshalIndent(fx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal fixture: %w", err)
+	}
+
+	path := filepath.Join(s.cfg.FixtureDir, fx.Hash+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write fixture: %w", err)
+	}
+
+	s.mu.Lock()
+	s.fixtures[fx.Hash] = fx
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Server) loadFixtures() error {
+	paths, err := filepath.Glob(filepath.Join(s.cfg.FixtureDir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("list fixtures: %w", err)
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read fixture: %w", err)
+		}
+
+		var fx Fixture
+		if err := json.Unmarshal(data, &fx); err != nil {
+			return fmt.Errorf("parse fixture %s: %w", filepath.Base(path), err)
+		}
+		s.fixtures[fx.Hash] = fx
+	}
+
+	return nil
+}
+
+// writeStream renders a complete response as a chat.completion.chunk stream.
+func writeStream(w http.ResponseWriter, resp *agents.ChatResponse) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+
+	choice := agents.ChatChoice{}
+	if len(resp.Choices) > 0 {
+		choice = resp.Choices[0]
+	}
+
+	chunks := []map[string]any{
+		{"model": resp.Model, "choices": []map[string]any{{"delta": map[string]any{"content": choice.Message.Content}}}},
+		{"model": resp.Model, "choices": []map[string]any{{"delta": map[string]any{}, "finish_reason": orDefault(choice.FinishReason, "stop")}}},
+		{"model": resp.Model, "choices": []map[string]any{}, "usage": resp.Usage},
+	}
+	for _, c := range chunks {
+		fmt.Fprintf(w, "data: %s\n\n", mustJSON(c))
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func errorBody(msg string) []byte {
+	return mustJSON(map[string]any{"error": map[string]string{"message": msg}})
+}
+
+func mustJSON(v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func orDefault(val, fallback string) string {
+	if val != "" {
+		return val
+	}
+	return fallback
+}
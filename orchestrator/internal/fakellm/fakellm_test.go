@@ -0,0 +1,133 @@
+package fakellm
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/agents"
+)
+
+func newClient(t *testing.T, url string) agents.StreamingProvider {
+	t.Helper()
+	p, err := agents.NewProvider(agents.ProviderOpenAI, agents.ProviderConfig{APIKey: "test", APIURL: url, Model: "test-model"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.(agents.StreamingProvider)
+}
+
+func request(content string) *agents.ChatRequest {
+	return &agents.ChatRequest{
+		Model:    "test-model",
+		Messages: []agents.ChatMessage{{Role: "system", Content: "You are terse."}, {Role: "user", Content: content}},
+	}
+}
+
+func TestPromptHash(t *testing.T) {
+	base := *request("Write  a\nplan.")
+	same := *request("Write a plan.")
+	same.Temperature = 0.7
+	same.MaxTokens = 100
+	same.Stream = true
+	if PromptHash(base) != PromptHash(same) {
+		t.Error("hash depends on whitespace or sampling parameters")
+	}
+
+	changed := *request("Write a test.")
+	if PromptHash(base) == PromptHash(changed) {
+		t.Error("hash ignores the message content")
+	}
+
+	withTools := base
+	withTools.Tools = []agents.ToolSpec{{Type: "function", Function: agents.ToolFunction{Name: "read_file"}}}
+	if PromptHash(base) == PromptHash(withTools) {
+		t.Error("hash ignores the offered tools")
+	}
+}
+
+func TestScript(t *testing.T) {
+	s := NewScripted("first", "second")
+	s.Push(Response{Status: http.StatusTooManyRequests, Body: `{"error":{"message":"slow down"}}`})
+	srv := s.Start()
+	defer srv.Close()
+	client := newClient(t, srv.URL)
+
+	resp, err := client.Chat(context.Background(), request("one"))
+	if err != nil || resp.Content() != "first" {
+		t.Fatalf("Chat = %v, %v", resp, err)
+	}
+	var streamed string
+	resp, err = client.ChatStream(context.Background(), request("two"), func(d string) { streamed += d })
+	if err != nil || resp.Content() != "second" || streamed != "second" {
+		t.Fatalf("ChatStream = %v, %q, %v", resp, streamed, err)
+	}
+	if _, err := client.Chat(context.Background(), request("three")); err == nil {
+		t.Error("scripted 429 did not fail")
+	}
+	if got := len(s.Requests()); got != 3 {
+		t.Errorf("recorded %d requests, want 3", got)
+	}
+}
+
+func TestRecordReplayRoundTrip(t *testing.T) {
+	upstream := NewScripted("recorded answer")
+	upstreamSrv := upstream.Start()
+	defer upstreamSrv.Close()
+
+	dir := t.TempDir()
+	recorder, err := New(Config{Mode: ModeRecord, FixtureDir: dir, UpstreamURL: upstreamSrv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordSrv := recorder.Start()
+	resp, err := newClient(t, recordSrv.URL).ChatStream(context.Background(), request("What is 2+2?"), func(string) {})
+	recordSrv.Close()
+	if err != nil || resp.Content() != "recorded answer" {
+		t.Fatalf("record: %v, %v", resp, err)
+	}
+	if upstream.Requests()[0].Stream {
+		t.Error("record mode streamed from upstream")
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 || filepath.Base(files[0]) != PromptHash(*request("What is 2+2?"))+".json" {
+		t.Fatalf("fixtures = %v", files)
+	}
+
+	replayer, err := New(Config{Mode: ModeReplay, FixtureDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaySrv := replayer.Start()
+	defer replaySrv.Close()
+	client := newClient(t, replaySrv.URL)
+
+	// Whitespace and sampling differences still hit the fixture, streamed
+	// or not.
+	req := request("What is  2+2?")
+	req.Temperature = 0.9
+	if resp, err := client.Chat(context.Background(), req); err != nil || resp.Content() != "recorded answer" {
+		t.Errorf("replay: %v, %v", resp, err)
+	}
+	if resp, err := client.ChatStream(context.Background(), request("What is 2+2?"), func(string) {}); err != nil || resp.Content() != "recorded answer" {
+		t.Errorf("streamed replay: %v, %v", resp, err)
+	}
+	if _, err := client.Chat(context.Background(), request("Something else")); err == nil {
+		t.Error("replay of an unrecorded prompt succeeded")
+	}
+	if len(upstream.Requests()) != 1 {
+		t.Error("replay reached the upstream")
+	}
+}
+
+func TestReplayBadFixture(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bad.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(Config{Mode: ModeReplay, FixtureDir: dir}); err == nil {
+		t.Error("loaded an invalid fixture")
+	}
+}
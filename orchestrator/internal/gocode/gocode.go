@@ -0,0 +1,513 @@
+// Package gocode resolves identifiers mentioned in task text or compiler
+// errors to their declarations in a Go module, with callers and package docs.
+// Sources are parsed with go/parser and type-checked with go/types so calls
+// resolve to the exact function or method. Only the module itself and the
+// standard library are loaded, which keeps it working when the module's
+// dependencies are not downloaded; calls that cannot be resolved, such as
+// those on values of dependency types or through interfaces, fall back to
+// matching by name.
+package gocode
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/ctxbudget"
+)
+
+const (
+	maxSnippetLines = 30
+	maxSymbols      = 10
+	maxDeclsPerName = 3
+	maxCallers      = 5
+	maxDocChars     = 300
+)
+
+// Package is one parsed Go package.
+type Package struct {
+	Path string // import path
+	Name string
+	Doc  string
+}
+
+// Decl is a top-level declaration.
+type Decl struct {
+	Package *Package
+	Name    string // "Foo", or "T.Foo" for methods
+	Kind    string // func, method, type, var or const
+	File    string // relative to the module directory
+	Line    int
+	Snippet string
+}
+
+// Ref is a call site.
+type Ref struct {
+	File   string
+	Line   int
+	Caller string
+}
+
+// Index holds the declarations and call sites of a module.
+type Index struct {
+	dir     string
+	module  string
+	std     types.Importer     // standard library, kept across refreshes
+	decls   map[string][]*Decl // by bare name, and by "T.Foo" for methods
+	callers map[*Decl][]Ref    // calls resolved by type checking
+	byName  map[string][]Ref   // calls that could not be resolved, by bare callee name
+}
+
+// Load parses and type-checks every non-test package below dir, the
+// directory of a go.mod. The standard library is type-checked from source
+// as it is imported, so the first Load is slow (around 4s for a typical
+// backend); Refresh reuses those packages and only re-checks the module.
+func Load(dir string) (*Index, error) {
+	idx := &Index{dir: dir, std: importer.ForCompiler(token.NewFileSet(), "source", nil)}
+	if err := idx.Refresh(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Refresh re-parses the module, picking up edits made since Load. If it
+// fails the previous index is kept.
+func (idx *Index) Refresh() error {
+	module, err := modulePath(filepath.Join(idx.dir, "go.mod"))
+	if err != nil {
+		return err
+	}
+
+	b := &builder{
+		dir:     idx.dir,
+		module:  module,
+		std:     idx.std,
+		fset:    token.NewFileSet(),
+		pkgs:    make(map[string]*pkgSource),
+		decls:   make(map[string][]*Decl),
+		defs:    make(map[*ast.Ident]*Decl),
+		callers: make(map[*Decl][]Ref),
+		byName:  make(map[string][]Ref),
+		info:    &types.Info{Defs: make(map[*ast.Ident]types.Object), Uses: make(map[*ast.Ident]types.Object)},
+	}
+	err = filepath.WalkDir(idx.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if path != idx.dir && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			return filepath.SkipDir
+		}
+		return b.parseDir(path)
+	})
+	if err != nil {
+		return err
+	}
+	b.resolve()
+
+	idx.module, idx.decls, idx.callers, idx.byName = module, b.decls, b.callers, b.byName
+	return nil
+}
+
+func modulePath(gomod string) (string, error) {
+	f, err := os.Open(gomod)
+	if err != nil {
+		return "", fmt.Errorf("open go.mod: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module "); ok {
+			return strings.Trim(strings.TrimSpace(rest), `"`), nil
+		}
+	}
+	return "", fmt.Errorf("no module directive in %s", gomod)
+}
+
+// builder collects a fresh index so a failed Refresh leaves the old one.
+type builder struct {
+	dir    string
+	module string
+	std    types.Importer
+	fset   *token.FileSet
+	pkgs   map[string]*pkgSource // by import path
+	info   *types.Info
+
+	decls   map[string][]*Decl
+	defs    map[*ast.Ident]*Decl // declaring name of each func and method
+	callers map[*Decl][]Ref
+	byName  map[string][]Ref
+}
+
+// pkgSource is a parsed package waiting to be type-checked.
+type pkgSource struct {
+	pkg      *Package
+	files    []*ast.File // files matching the current build constraints
+	funcs    []funcSource
+	types    *types.Package
+	checking bool
+}
+
+type funcSource struct {
+	file string
+	name string
+	decl *ast.FuncDecl
+}
+
+func (b *builder) parseDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	rel, _ := filepath.Rel(b.dir, dir)
+	pkg := &Package{Path: b.module}
+	if rel != "." {
+		pkg.Path += "/" + filepath.ToSlash(rel)
+	}
+	ps := &pkgSource{pkg: pkg}
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		// Syntax errors are common mid-fix; index whatever part parsed.
+		f, _ := parser.ParseFile(b.fset, path, src, parser.ParseComments)
+		if f == nil {
+			continue
+		}
+
+		pkg.Name = f.Name.Name
+		if pkg.Doc == "" && f.Doc != nil {
+			pkg.Doc = ctxbudget.Truncate(strings.TrimSpace(f.Doc.Text()), maxDocChars/4)
+		}
+		fileRel, _ := filepath.Rel(b.dir, path)
+		b.indexFile(ps, filepath.ToSlash(fileRel), f, src)
+		if ok, err := build.Default.MatchFile(dir, name); err == nil && ok {
+			ps.files = append(ps.files, f)
+		}
+	}
+	if pkg.Name != "" {
+		b.pkgs[pkg.Path] = ps
+	}
+	return nil
+}
+
+func (b *builder) indexFile(ps *pkgSource, file string, f *ast.File, src []byte) {
+	add := func(name, kind string, node ast.Node, snippet string) *Decl {
+		d := &Decl{
+			Package: ps.pkg,
+			Name:    name,
+			Kind:    kind,
+			File:    file,
+			Line:    b.fset.Position(node.Pos()).Line,
+			Snippet: snippet,
+		}
+		bare := name[strings.LastIndex(name, ".")+1:]
+		b.decls[bare] = append(b.decls[bare], d)
+		if bare != name {
+			b.decls[name] = append(b.decls[name], d)
+		}
+		return d
+	}
+
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			kind, name := "func", d.Name.Name
+			if recv := receiverType(d); recv != "" {
+				kind, name = "method", recv+"."+d.Name.Name
+			}
+			b.defs[d.Name] = add(name, kind, d, funcSnippet(b.fset, src, d))
+			ps.funcs = append(ps.funcs, funcSource{file: file, name: name, decl: d})
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					add(s.Name.Name, "type", s, specSnippet(b.fset, src, d, s, s.Doc))
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						add(n.Name, d.Tok.String(), s, specSnippet(b.fset, src, d, s, s.Doc))
+					}
+				}
+			}
+		}
+	}
+}
+
+// Import type-checks module packages on demand and loads the standard
+// library from source. Other imports fail, leaving their uses unresolved.
+func (b *builder) Import(path string) (*types.Package, error) {
+	ps, ok := b.pkgs[path]
+	if !ok {
+		if path == b.module || strings.HasPrefix(path, b.module+"/") || strings.Contains(strings.Split(path, "/")[0], ".") {
+			return nil, fmt.Errorf("package %s is not loaded", path)
+		}
+		return b.std.Import(path)
+	}
+	if ps.checking {
+		return nil, fmt.Errorf("import cycle through %s", path)
+	}
+	if ps.types == nil {
+		ps.checking = true
+		// Type errors are expected while the code is broken; whatever
+		// resolved is still recorded in info.
+		conf := types.Config{Importer: b, FakeImportC: true, Error: func(error) {}}
+		ps.types, _ = conf.Check(path, b.fset, ps.files, b.info)
+		ps.checking = false
+	}
+	return ps.types, nil
+}
+
+// resolve type-checks every package and attributes each call to the
+// declaration it resolves to.
+func (b *builder) resolve() {
+	paths := make([]string, 0, len(b.pkgs))
+	for path := range b.pkgs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		b.Import(path)
+	}
+
+	objs := make(map[types.Object]*Decl)
+	for ident, d := range b.defs {
+		if obj := b.info.Defs[ident]; obj != nil {
+			objs[obj] = d
+		}
+	}
+	for _, path := range paths {
+		for _, fn := range b.pkgs[path].funcs {
+			b.indexCalls(objs, fn)
+		}
+	}
+}
+
+func (b *builder) indexCalls(objs map[types.Object]*Decl, fn funcSource) {
+	if fn.decl.Body == nil {
+		return
+	}
+	ast.Inspect(fn.decl.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var callee *ast.Ident
+		switch fun := call.Fun.(type) {
+		case *ast.Ident:
+			callee = fun
+		case *ast.SelectorExpr:
+			callee = fun.Sel
+		case *ast.IndexExpr: // explicit instantiation, f[T](x)
+			callee, _ = ast.Unparen(fun.X).(*ast.Ident)
+		}
+		if callee == nil {
+			return true
+		}
+
+		ref := Ref{File: fn.file, Line: b.fset.Position(call.Pos()).Line, Caller: fn.name}
+		obj := b.info.Uses[callee]
+		if f, ok := obj.(*types.Func); ok {
+			obj = f.Origin()
+			if d := objs[obj]; d != nil {
+				b.callers[d] = append(b.callers[d], ref)
+				return true
+			}
+			if recv := f.Signature().Recv(); recv == nil || !types.IsInterface(recv.Type()) {
+				return true // a function outside the module
+			}
+		} else if obj != nil {
+			return true // a conversion, builtin or func value
+		}
+		// Unresolved, or a call through an interface that any method of
+		// that name may implement.
+		b.byName[callee.Name] = append(b.byName[callee.Name], ref)
+		return true
+	})
+}
+
+func receiverType(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return ""
+	}
+	expr := fn.Recv.List[0].Type
+	for {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.IndexExpr:
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
+
+func source(fset *token.FileSet, src []byte, from, to token.Pos) string {
+	start, end := fset.Position(from).Offset, fset.Position(to).Offset
+	if start < 0 || end > len(src) || start > end {
+		return ""
+	}
+	return string(src[start:end])
+}
+
+// funcSnippet is the whole function, or only its doc and signature when the
+// body is long.
+func funcSnippet(fset *token.FileSet, src []byte, fn *ast.FuncDecl) string {
+	from := fn.Pos()
+	if fn.Doc != nil {
+		from = fn.Doc.Pos()
+	}
+	text := source(fset, src, from, fn.End())
+	if fn.Body == nil || strings.Count(text, "\n") < maxSnippetLines {
+		return text
+	}
+	return strings.TrimSpace(source(fset, src, from, fn.Body.Lbrace)) + " { ... }"
+}
+
+func specSnippet(fset *token.FileSet, src []byte, d *ast.GenDecl, spec ast.Spec, doc *ast.CommentGroup) string {
+	var text string
+	if len(d.Specs) == 1 {
+		from := d.Pos()
+		if d.Doc != nil {
+			from = d.Doc.Pos()
+		}
+		text = source(fset, src, from, d.End())
+	} else {
+		from := spec.Pos()
+		if doc != nil {
+			from = doc.Pos()
+		}
+		text = d.Tok.String() + " " + source(fset, src, from, spec.End())
+	}
+
+	lines := strings.Split(text, "\n")
+	if len(lines) > maxSnippetLines {
+		text = strings.Join(lines[:maxSnippetLines], "\n") + "\n\t// ..."
+	}
+	return text
+}
+
+var (
+	identRe     = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)?`)
+	undefinedRe = regexp.MustCompile(`(?:undefined|declared and not used|not declared by package \w+):\s*([A-Za-z_][A-Za-z0-9_.]*)`)
+)
+
+// Identifiers returns the names in text that are declared in the module, in
+// order of first appearance. A method written as "T.Foo" is returned as
+// such, so only that type's Foo is shown. Plain lowercase words are only
+// taken from compiler errors such as "undefined: foo", since in prose they
+// are usually English.
+func (idx *Index) Identifiers(text string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if len(name) < 3 || seen[name] || len(idx.decls[name]) == 0 {
+			return
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	for _, m := range undefinedRe.FindAllStringSubmatch(text, -1) {
+		parts := strings.Split(m[1], ".")
+		add(parts[len(parts)-1])
+	}
+	for _, ident := range identRe.FindAllString(text, -1) {
+		recv, method, qualified := strings.Cut(ident, ".")
+		if qualified && len(idx.decls[ident]) > 0 {
+			add(ident)
+			seen[method] = true
+			continue
+		}
+		for _, part := range []string{recv, method} {
+			if part != strings.ToLower(part) || strings.ContainsAny(part, "_0123456789") {
+				add(part)
+			}
+		}
+	}
+	return names
+}
+
+// Context renders the declarations of the identifiers found in text, with
+// their callers and package docs, in at most maxTokens (0 is unlimited).
+// It returns the snippet text and the symbols included.
+func (idx *Index) Context(text string, maxTokens int) (string, []string) {
+	var b strings.Builder
+	var included []string
+	docs := make(map[*Package]bool)
+
+	names := idx.Identifiers(text)
+	for _, name := range names[:min(len(names), maxSymbols)] {
+		decls := idx.decls[name]
+		for _, d := range decls[:min(len(decls), maxDeclsPerName)] {
+			block := idx.render(d, !docs[d.Package])
+			if maxTokens > 0 && ctxbudget.EstimateTokens(b.String()+block) > maxTokens {
+				return b.String(), included
+			}
+			b.WriteString(block)
+			docs[d.Package] = true
+			included = append(included, d.Package.Name+"."+d.Name)
+		}
+	}
+	return b.String(), included
+}
+
+func (idx *Index) render(d *Decl, withDoc bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s %s.%s (%s:%d) ---\n", d.Kind, d.Package.Name, d.Name, d.File, d.Line)
+	if withDoc && d.Package.Doc != "" {
+		fmt.Fprintf(&b, "// package %s (%s): %s\n", d.Package.Name, d.Package.Path, strings.ReplaceAll(d.Package.Doc, "\n", " "))
+	}
+	b.WriteString(d.Snippet)
+	b.WriteString("\n")
+
+	if d.Kind != "func" && d.Kind != "method" {
+		return b.String()
+	}
+	writeRefs(&b, "called from", idx.callers[d])
+	bare := d.Name[strings.LastIndex(d.Name, ".")+1:]
+	writeRefs(&b, "calls to any "+bare+", unresolved or through an interface", idx.byName[bare])
+	return b.String()
+}
+
+func writeRefs(b *strings.Builder, label string, refs []Ref) {
+	if len(refs) == 0 {
+		return
+	}
+	sites := make([]string, 0, maxCallers)
+	for _, r := range refs[:min(len(refs), maxCallers)] {
+		sites = append(sites, fmt.Sprintf("%s (%s:%d)", r.Caller, r.File, r.Line))
+	}
+	if extra := len(refs) - len(sites); extra > 0 {
+		sites = append(sites, fmt.Sprintf("+%d more", extra))
+	}
+	fmt.Fprintf(b, "// %s: %s\n", label, strings.Join(sites, ", "))
+}
@@ -0,0 +1,153 @@
+package gocode
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+var testModule = map[string]string{
+	"go.mod": "module example.com/app\n\ngo 1.22\n",
+	"store/store.go": `// Package store persists readings.
+package store
+
+import "strings"
+
+type Store struct{}
+
+// Create saves a reading.
+func (s *Store) Create(name string) error {
+	_ = strings.TrimSpace(name)
+	return nil
+}
+
+type Cache struct{}
+
+// Create caches a reading.
+func (c *Cache) Create(name string) error { return nil }
+
+type Creator interface {
+	Create(name string) error
+}
+`,
+	"api/handler.go": `package api
+
+import (
+	"example.com/app/store"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SaveReading(s *store.Store, name string) error {
+	return s.Create(name)
+}
+
+func CacheReading(c *store.Cache, name string) error {
+	return c.Create(name)
+}
+
+func CreateAny(c store.Creator) error {
+	return c.Create("x")
+}
+
+func Handle(ctx *fiber.Ctx) error {
+	return ctx.Create()
+}
+`,
+}
+
+func TestContextResolvesMethodsByReceiver(t *testing.T) {
+	idx, err := Load(writeModule(t, testModule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, symbols := idx.Context("SaveReading fails in Store.Create", 0)
+	if strings.Join(symbols, ",") != "api.SaveReading,store.Store.Create" {
+		t.Fatalf("symbols = %v", symbols)
+	}
+	store := text[strings.Index(text, "--- method store.Store.Create"):]
+	if !strings.Contains(store, "// called from: SaveReading (api/handler.go:10)") {
+		t.Errorf("Store.Create callers missing:\n%s", store)
+	}
+	if strings.Contains(store, "CacheReading") {
+		t.Errorf("Cache.Create's caller attributed to Store.Create:\n%s", store)
+	}
+	// The interface call and the call on a type from an undownloaded
+	// dependency can only be matched by name.
+	if !strings.Contains(store, "// calls to any Create, unresolved or through an interface: CreateAny (api/handler.go:18), Handle (api/handler.go:22)") {
+		t.Errorf("name-matched callers missing:\n%s", store)
+	}
+
+	_, symbols = idx.Context("Create is broken", 0)
+	if strings.Join(symbols, ",") != "store.Store.Create,store.Cache.Create" {
+		t.Errorf("bare name symbols = %v", symbols)
+	}
+}
+
+func TestContextSyntaxErrorFallsBackToNames(t *testing.T) {
+	files := map[string]string{}
+	for name, content := range testModule {
+		files[name] = content
+	}
+	files["api/broken.go"] = "package api\n\nfunc Broken(s *store.Store) {\n\ts.Create(\"x\")\n\tif {\n}\n"
+	idx, err := Load(writeModule(t, files))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, _ := idx.Context("Store.Create", 0)
+	if !strings.Contains(text, "// called from: SaveReading (api/handler.go:10)") {
+		t.Errorf("resolved callers lost next to a broken file:\n%s", text)
+	}
+	if !strings.Contains(text, "Broken (api/broken.go:4)") {
+		t.Errorf("caller in the broken file not matched by name:\n%s", text)
+	}
+}
+
+func TestRefreshFailureKeepsIndex(t *testing.T) {
+	dir := writeModule(t, testModule)
+	idx, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "go.mod")); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Refresh(); err == nil {
+		t.Fatal("Refresh without go.mod succeeded")
+	}
+	if _, symbols := idx.Context("Store.Create", 0); len(symbols) != 1 {
+		t.Errorf("symbols after a failed refresh = %v", symbols)
+	}
+
+	// A walk that fails halfway must not leave a half-built index.
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(testModule["go.mod"]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("missing.go", filepath.Join(dir, "store", "dangling.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Refresh(); err == nil {
+		t.Fatal("Refresh with an unreadable file succeeded")
+	}
+	if _, symbols := idx.Context("Store.Create", 0); len(symbols) != 1 {
+		t.Errorf("symbols after a failed walk = %v", symbols)
+	}
+}
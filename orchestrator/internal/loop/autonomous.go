@@ -2,18 +2,36 @@ package loop
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/agents"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/configedit"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/ctxbudget"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/diag"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/gocode"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/patch"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/prompts"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/retrieval"
 	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/task"
 )
 
 // AgentSet holds all available agents.
 type AgentSet struct {
 	Engine      *agents.Engine
-	Executioner *agents.Executioner
+	Executioner *agents.Executioner // runs the test command
 	Debugger    *agents.Debugger
+
+	// Backends carry out execute and fix prompts, by name. A task picks one
+	// with its backend field; DefaultBackend is used otherwise.
+	Backends       map[string]agents.ExecutionBackend
+	DefaultBackend string
 }
 
 // LoopConfig configures the autonomous loop.
@@ -21,22 +39,76 @@ type LoopConfig struct {
 	MaxRetries  int
 	TestCommand string
 	ProjectDir  string
+
+	// Ledger tracks token usage and budgets; may be nil.
+	Ledger *agents.Ledger
+	// Tasks persists per-task results such as usage; may be nil.
+	Tasks *task.Manager
+	// Prompts renders the phase prompts; nil uses the embedded defaults.
+	Prompts *prompts.Set
+
+	// Prompt budgets in tokens for each agent; 0 is unlimited.
+	EngineContextTokens      int
+	DebuggerContextTokens    int
+	ExecutionerContextTokens int
+	// BackendContextTokens overrides ExecutionerContextTokens for the
+	// execute and fix prompts of the named backends.
+	BackendContextTokens map[string]int
+
+	// DebugMemoryTurns is how many past fix attempts the Debugger sees in full.
+	DebugMemoryTurns int
+
+	// Retriever supplies relevant project code to the plan and debug
+	// prompts; may be nil.
+	Retriever *retrieval.Index
+	// RetrievalTopK chunks are retrieved, up to RetrievalTokens in total.
+	RetrievalTopK   int
+	RetrievalTokens int
+
+	// FreshSessions starts every execution in a new session instead of
+	// resuming the task's session.
+	FreshSessions bool
+
+	// GoCode resolves Go identifiers in the task and compiler errors to
+	// their declarations, up to GoContextTokens; may be nil.
+	GoCode          *gocode.Index
+	GoContextTokens int
+
+	// CommandPolicy decides which Debugger command fixes are run; nil uses
+	// agents.DefaultCommandPolicy.
+	CommandPolicy *agents.CommandPolicy
 }
 
 // RunAutonomousLoop executes the Plan → Execute → Test → Correct → Deploy loop.
+// It stops with a *agents.BudgetExceededError when a cost budget runs out.
 func RunAutonomousLoop(ctx context.Context, t *task.Task, agentSet *AgentSet, cfg *LoopConfig) error {
 	log.Printf("[LOOP] Starting task: %s", t.Title)
 
+	if cfg.Ledger != nil {
+		cfg.Ledger.StartTask(t.ID)
+		defer recordUsage(t, cfg)
+	}
+
+	promptSet := cfg.Prompts
+	if promptSet == nil {
+		promptSet = prompts.Default()
+	}
+
 	// Phase 1: PLAN
 	log.Println("[LOOP] Phase 1: Planning...")
-	planPrompt := fmt.Sprintf(
-		"Create a detailed implementation plan for the following task:\n\n"+
-			"Title: %s\nDescription: %s\n\n"+
-			"Output a step-by-step plan with file paths and code changes needed.",
-		t.Title, t.Description,
-	)
-
-	plan, err := agentSet.Engine.Execute(ctx, planPrompt)
+	planContext := retrieveContext(cfg, t.Title+"\n"+t.Description, false)
+	planPrompt, err := renderPrompt(promptSet, prompts.Plan, prompts.Data{
+		Task:    t,
+		Context: planContext,
+		Symbols: symbolContext(cfg, t.Title+"\n"+t.Description, false),
+	}, cfg.EngineContextTokens)
+	if err != nil {
+		return err
+	}
+
+	planCtx, flushPlan := withStreamLog(ctx, "[ENGINE]")
+	plan, err := agentSet.Engine.Execute(planCtx, planPrompt)
+	flushPlan()
 	if err != nil {
 		return fmt.Errorf("planning failed: %w", err)
 	}
@@ -44,31 +116,50 @@ func RunAutonomousLoop(ctx context.Context, t *task.Task, agentSet *AgentSet, cf
 
 	// Phase 2: EXECUTE
 	log.Println("[LOOP] Phase 2: Executing...")
-	execPrompt := fmt.Sprintf(
-		"Implement the following plan. Create or modify files as needed.\n\n"+
-			"Plan:\n%s\n\n"+
-			"Task: %s\nDescription: %s",
-		plan, t.Title, t.Description,
-	)
-
-	execResult, err := agentSet.Executioner.Execute(ctx, execPrompt)
+	execPrompt, err := renderPrompt(promptSet, prompts.Execute, prompts.Data{Task: t, Plan: plan}, execBudget(agentSet, t, cfg))
+	if err != nil {
+		return err
+	}
+
+	execRes, err := execute(ctx, agentSet, t, cfg, execPrompt)
 	if err != nil {
 		return fmt.Errorf("execution failed: %w", err)
 	}
-	log.Printf("[LOOP] Execution complete (%d chars output)", len(execResult))
+	execResult := execRes.String()
+	log.Printf("[LOOP] Execution complete in %s: %d files edited, %d turns, $%.4f",
+		execRes.Duration.Round(time.Second), len(execRes.FilesEdited), execRes.Turns, execRes.CostUSD)
 
 	// Phase 3-4: TEST → CORRECT (retry loop)
+	memory := agents.NewDebugMemory(cfg.DebugMemoryTurns)
+	if cfg.DebuggerContextTokens > 0 {
+		// Past attempts take at most a third of the Debugger's budget; the
+		// debug prompt is fitted into what they leave.
+		memory.SetMaxTokens(cfg.DebuggerContextTokens / 3)
+	}
+	testDir := diag.CommandDir(cfg.ProjectDir, cfg.TestCommand)
+	var errorCounts []string
 	for attempt := 1; attempt <= cfg.MaxRetries; attempt++ {
 		log.Printf("[LOOP] Phase 3: Testing (attempt %d/%d)...", attempt, cfg.MaxRetries)
 
-		testOutput, testErr := agentSet.Executioner.RunShellCommand(ctx, cfg.TestCommand)
+		testRes, testErr := agentSet.Executioner.RunShellCommand(ctx, cfg.TestCommand)
+		testOutput := testRes.Output()
 		if testErr == nil {
 			log.Println("[LOOP] Tests passed!")
 			log.Printf("[LOOP] Test output: %s", testOutput)
 			return nil // All tests pass — success!
 		}
+		var cmdErr *agents.CommandError
+		if !errors.As(testErr, &cmdErr) {
+			return fmt.Errorf("run tests: %w", testErr)
+		}
+
+		log.Printf("[LOOP] Tests failed: %v (%s, %d chars output)", testErr, testRes.Duration.Round(time.Millisecond), len(testOutput))
 
-		log.Printf("[LOOP] Tests failed: %v", testErr)
+		diags := diag.Parse(testOutput)
+		errs, warnings := diag.Count(diags)
+		errorCounts = append(errorCounts, strconv.Itoa(errs))
+		log.Printf("[LOOP] Attempt %d: %d errors, %d warnings (per attempt: %s)",
+			attempt, errs, warnings, strings.Join(errorCounts, " → "))
 
 		if attempt == cfg.MaxRetries {
 			return fmt.Errorf("tests failed after %d retries: %w", cfg.MaxRetries, testErr)
@@ -76,38 +167,373 @@ func RunAutonomousLoop(ctx context.Context, t *task.Task, agentSet *AgentSet, cf
 
 		// Phase 4: CORRECT
 		log.Println("[LOOP] Phase 4: Debugging...")
-		debugPrompt := fmt.Sprintf(
-			"The following test command failed:\n\nCommand: %s\n\n"+
-				"Error output:\n%s\n\n"+
-				"Previous execution result:\n%s\n\n"+
-				"Analyze the error and provide a fix.",
-			cfg.TestCommand, testOutput, execResult,
-		)
-
-		fix, err := agentSet.Debugger.Execute(ctx, debugPrompt)
+		debugPrompt, err := renderPrompt(promptSet, prompts.Debug, prompts.Data{
+			Task:        t,
+			Plan:        plan,
+			ExecResult:  execResult,
+			TestCommand: cfg.TestCommand,
+			TestOutput:  testOutput,
+			Attempt:     attempt,
+			MaxAttempts: cfg.MaxRetries,
+			Context:     retrieveContext(cfg, t.Title+"\n"+testOutput, true),
+			Symbols:     symbolContext(cfg, testOutput+"\n"+t.Title+"\n"+t.Description, true),
+			Diagnostics: diag.Render(testDir, diags, 3, 20),
+		}, debugBudget(cfg.DebuggerContextTokens, memory))
 		if err != nil {
+			return err
+		}
+
+		debugCtx, flushDebug := withStreamLog(ctx, "[DEBUGGER]")
+		fix, err := agentSet.Debugger.Diagnose(debugCtx, debugPrompt, memory)
+		flushDebug()
+		if err != nil {
+			if isBudgetError(err) {
+				return err
+			}
 			log.Printf("[LOOP] Debugger error: %v", err)
 			continue
 		}
 
-		log.Printf("[LOOP] Fix generated, applying...")
+		log.Printf("[LOOP] Fix generated (%s): %s", fix.FixType, fix.Analysis)
+		memory.AddFix(attempt, testOutput, fix)
 
-		// Apply fix via executioner
-		fixPrompt := fmt.Sprintf(
-			"Apply the following fix to the codebase:\n\n%s",
-			fix,
-		)
-
-		execResult, err = agentSet.Executioner.Execute(ctx, fixPrompt)
+		var outcome string
+		execResult, outcome, err = applyFix(ctx, agentSet, t, cfg, promptSet, fix, attempt)
 		if err != nil {
-			log.Printf("[LOOP] Fix application failed: %v", err)
-			continue
+			return err
 		}
+		memory.SetOutcome(outcome)
 	}
 
 	return fmt.Errorf("autonomous loop exhausted all retries")
 }
 
+// applyFix carries out a Debugger fix. It returns the result shown in the
+// next debug prompt and the outcome the Debugger remembers; the error is
+// only set when the loop can't continue. Code patches that parse as diffs
+// or file blocks, commands and structured config changes are applied
+// directly; everything else goes to the execution backend.
+func applyFix(ctx context.Context, agentSet *AgentSet, t *task.Task, cfg *LoopConfig, promptSet *prompts.Set, fix *agents.DebugResult, attempt int) (string, string, error) {
+	switch fix.FixType {
+	case agents.FixTypeCommand:
+		return runFixCommand(ctx, agentSet, cfg, fix.FixContent)
+	case agents.FixTypeConfigChange:
+		changes, err := configedit.Parse(fix.FixContent)
+		if err == nil {
+			return applyConfigChanges(ctx, agentSet, cfg, changes)
+		}
+		log.Printf("[LOOP] Config change is not structured (%v); sending it to the execution backend", err)
+	case agents.FixTypeCodePatch:
+		if edits, _ := patch.Parse(fix.FixContent); len(edits) > 0 {
+			report := patch.NewApplier(cfg.ProjectDir).Apply(edits)
+			applied, failed := report.Counts()
+			log.Printf("[LOOP] Patch applied directly: %d applied, %d failed\n%s", applied, failed, report)
+
+			outcome := "patch applied:\n" + report.String()
+			switch {
+			case applied == 0:
+				outcome = "patch failed, nothing was changed:\n" + report.String()
+			case failed > 0:
+				outcome = fmt.Sprintf("patch partly applied, %d of %d edits failed:\n%s", failed, applied+failed, report)
+			}
+			return "Patch result:\n" + report.String(), outcome, nil
+		}
+		log.Println("[LOOP] Patch has no diff or file blocks; sending it to the execution backend")
+	}
+
+	fixPrompt, err := renderPrompt(promptSet, prompts.Fix, prompts.Data{
+		Task:        t,
+		Attempt:     attempt,
+		MaxAttempts: cfg.MaxRetries,
+		Fix: &prompts.FixData{
+			Analysis:   fix.Analysis,
+			FixType:    fix.FixType,
+			FixContent: fix.FixContent,
+		},
+	}, execBudget(agentSet, t, cfg))
+	if err != nil {
+		return "", "", err
+	}
+
+	execRes, err := execute(ctx, agentSet, t, cfg, fixPrompt)
+	if isBudgetError(err) {
+		return "", "", err
+	}
+	if err != nil {
+		log.Printf("[LOOP] Fix application failed: %v", err)
+		return execRes.String(), fmt.Sprintf("could not be applied: %v", err), nil
+	}
+	return execRes.String(), "applied by the executioner", nil
+}
+
+// runFixCommand runs a Debugger command fix in the project root if the
+// command policy allows it.
+func runFixCommand(ctx context.Context, agentSet *AgentSet, cfg *LoopConfig, command string) (string, string, error) {
+	command = strings.TrimSpace(strings.Trim(strings.TrimSpace(command), "`"))
+	policy := cfg.CommandPolicy
+	if policy == nil {
+		policy = agents.DefaultCommandPolicy()
+	}
+	if err := policy.Check(cfg.ProjectDir, command); err != nil {
+		log.Printf("[LOOP] Fix command rejected: %v", err)
+		return "Command not run: " + err.Error(), fmt.Sprintf("command `%s` rejected by policy, not run: %v", command, err), nil
+	}
+
+	log.Printf("[LOOP] Running fix command: %s", command)
+	res, err := agentSet.Executioner.RunShellCommand(ctx, command)
+	var cmdErr *agents.CommandError
+	if err != nil && !errors.As(err, &cmdErr) {
+		if ctx.Err() != nil {
+			return "", "", err
+		}
+		return "Command failed: " + err.Error(), fmt.Sprintf("command `%s` could not be run: %v", command, err), nil
+	}
+
+	output := res.Output()
+	log.Printf("[LOOP] Fix command exited with code %d in %s", res.ExitCode, res.Duration.Round(time.Millisecond))
+	outcome := fmt.Sprintf("command `%s` exited with code %d in %s:\n%s",
+		command, res.ExitCode, res.Duration.Round(time.Millisecond), ctxbudget.HeadTail(output, 300))
+	if res.TimedOut {
+		outcome = fmt.Sprintf("command `%s` timed out after %s:\n%s", command, res.Duration.Round(time.Second), ctxbudget.HeadTail(output, 300))
+	}
+	return "Command result:\n$ " + command + "\n" + output, outcome, nil
+}
+
+// applyConfigChanges makes structured config edits, reporting each one.
+// Modules whose go.mod changed are tidied so go.sum matches.
+func applyConfigChanges(ctx context.Context, agentSet *AgentSet, cfg *LoopConfig, changes []configedit.Change) (string, string, error) {
+	var report strings.Builder
+	failed := 0
+	var modDirs []string
+	for _, c := range changes {
+		if err := configedit.Apply(cfg.ProjectDir, c); err != nil {
+			failed++
+			fmt.Fprintf(&report, "FAILED %s: %v\n", c, err)
+			continue
+		}
+		fmt.Fprintf(&report, "ok %s\n", c)
+		if dir := filepath.ToSlash(filepath.Dir(filepath.Clean(c.File))); filepath.Base(c.File) == "go.mod" && !slices.Contains(modDirs, dir) {
+			modDirs = append(modDirs, dir)
+		}
+	}
+	log.Printf("[LOOP] Config changes applied directly: %d applied, %d failed\n%s", len(changes)-failed, failed, report.String())
+
+	for _, dir := range modDirs {
+		command := "go mod tidy"
+		if dir != "." {
+			command = "cd " + dir + " && go mod tidy"
+		}
+		_, tidyOutcome, err := runFixCommand(ctx, agentSet, cfg, command)
+		if err != nil {
+			return "", "", err
+		}
+		report.WriteString(tidyOutcome + "\n")
+	}
+
+	outcome := "config changed:\n" + report.String()
+	switch {
+	case failed == len(changes):
+		outcome = "config change failed, nothing was changed:\n" + report.String()
+	case failed > 0:
+		outcome = fmt.Sprintf("config partly changed, %d of %d changes failed:\n%s", failed, len(changes), report.String())
+	}
+	return "Config change result:\n" + report.String(), outcome, nil
+}
+
+// debugBudget is the token budget left for the debug prompt once the
+// Debugger's replayed history is counted; 0 stays unlimited.
+func debugBudget(limit int, memory *agents.DebugMemory) int {
+	if limit <= 0 {
+		return 0
+	}
+	return max(limit-memory.Tokens(), 1)
+}
+
+// backendName returns the execution backend a task runs with.
+func backendName(agentSet *AgentSet, t *task.Task) string {
+	if t.Backend != "" {
+		return t.Backend
+	}
+	return agentSet.DefaultBackend
+}
+
+// execBudget is the token budget for a task's execute and fix prompts,
+// which depends on the backend that receives them.
+func execBudget(agentSet *AgentSet, t *task.Task, cfg *LoopConfig) int {
+	if n, ok := cfg.BackendContextTokens[backendName(agentSet, t)]; ok {
+		return n
+	}
+	return cfg.ExecutionerContextTokens
+}
+
+// execute runs prompt through the task's execution backend, resuming the
+// task's session unless fresh sessions are forced, and saves the session it
+// ran in.
+func execute(ctx context.Context, agentSet *AgentSet, t *task.Task, cfg *LoopConfig, prompt string) (*agents.ExecutionSummary, error) {
+	name := backendName(agentSet, t)
+	backend, ok := agentSet.Backends[name]
+	if !ok {
+		return &agents.ExecutionSummary{}, fmt.Errorf("execution backend %q is not configured", name)
+	}
+
+	req := agents.ExecRequest{Prompt: prompt}
+	if !cfg.FreshSessions {
+		req.SessionID = t.SessionID
+	}
+	if cfg.Ledger != nil {
+		if err := cfg.Ledger.Check(); err != nil {
+			return &agents.ExecutionSummary{}, err
+		}
+	}
+	log.Printf("[LOOP] Executing with %s backend", backend.Name())
+	summary, err := backend.Execute(ctx, req)
+
+	// External CLIs report their own cost; backends that call the Engine
+	// are recorded by it and leave CostUSD at zero.
+	if cfg.Ledger != nil && summary.CostUSD > 0 {
+		if budgetErr := cfg.Ledger.RecordCost("Executioner", backend.Name(), summary.Usage, summary.CostUSD); budgetErr != nil && err == nil {
+			err = budgetErr
+		}
+	}
+
+	if summary.SessionID != "" && summary.SessionID != t.SessionID {
+		t.SessionID = summary.SessionID
+		if cfg.Tasks != nil {
+			if err := cfg.Tasks.SetSessionID(t.ID, t.SessionID); err != nil {
+				log.Printf("[LOOP] Failed to save session ID: %v", err)
+			}
+		}
+	}
+	return summary, err
+}
+
+// renderPrompt renders a phase prompt and logs which template version
+// produced it. With a non-zero limit the plan, execution result and test
+// output are shrunk first so the prompt fits limit tokens.
+func renderPrompt(set *prompts.Set, name string, data prompts.Data, limit int) (string, error) {
+	tmpl, err := set.Get(name)
+	if err != nil {
+		return "", err
+	}
+
+	if limit > 0 {
+		if err := fitData(tmpl, &data, limit); err != nil {
+			return "", err
+		}
+	}
+
+	prompt, err := tmpl.Render(data)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("[LOOP] Prompt %s@%s (%s, %d chars)", tmpl.Name, tmpl.Version, tmpl.Source, len(prompt))
+	return prompt, nil
+}
+
+// fitData shrinks the variable-size fields of data so the rendered template
+// fits limit tokens, and logs what was dropped.
+func fitData(tmpl *prompts.Template, data *prompts.Data, limit int) error {
+	bare := *data
+	bare.Plan, bare.ExecResult, bare.TestOutput = "", "", ""
+	bare.Context, bare.Symbols, bare.Diagnostics = "", "", ""
+	skeleton, err := tmpl.Render(bare)
+	if err != nil {
+		return err
+	}
+
+	testOutput := &ctxbudget.Section{Name: "test_output", Text: data.TestOutput, Kind: ctxbudget.Log, Priority: 3, MinTokens: 1000}
+	plan := &ctxbudget.Section{Name: "plan", Text: data.Plan, Kind: ctxbudget.Prose, Priority: 2, MinTokens: 1000}
+	execResult := &ctxbudget.Section{Name: "exec_result", Text: data.ExecResult, Kind: ctxbudget.Stale, Priority: 1, MinTokens: 300}
+	codeContext := &ctxbudget.Section{Name: "context", Text: data.Context, Kind: ctxbudget.Prose, Priority: 0}
+	symbols := &ctxbudget.Section{Name: "symbols", Text: data.Symbols, Kind: ctxbudget.Prose, Priority: 1}
+	diagnostics := &ctxbudget.Section{Name: "diagnostics", Text: data.Diagnostics, Kind: ctxbudget.Prose, Priority: 2, MinTokens: 500}
+
+	sections := []*ctxbudget.Section{testOutput, plan, execResult, codeContext, symbols, diagnostics}
+	report := ctxbudget.Fit(sections, limit-ctxbudget.EstimateTokens(skeleton))
+	if len(report.Dropped) > 0 {
+		log.Printf("[LOOP] Context budget %s: %s", tmpl.Name, report)
+	}
+
+	data.TestOutput, data.Plan, data.ExecResult = testOutput.Text, plan.Text, execResult.Text
+	data.Context, data.Symbols, data.Diagnostics = codeContext.Text, symbols.Text, diagnostics.Text
+	return nil
+}
+
+// retrieveContext returns the project chunks most relevant to query, within
+// the retrieval budget. With refresh the index first picks up files the
+// Executioner changed.
+func retrieveContext(cfg *LoopConfig, query string, refresh bool) string {
+	if cfg.Retriever == nil {
+		return ""
+	}
+	if refresh {
+		if err := cfg.Retriever.Refresh(); err != nil {
+			log.Printf("[LOOP] Retrieval index refresh failed: %v", err)
+		}
+	}
+
+	query = ctxbudget.Truncate(query, 1000)
+	chunks := cfg.Retriever.Search(query, cfg.RetrievalTopK)
+	text, used := retrieval.Format(chunks, cfg.RetrievalTokens)
+
+	paths := make([]string, used)
+	for i, c := range chunks[:used] {
+		paths[i] = fmt.Sprintf("%s:%d", c.Path, c.StartLine)
+	}
+	log.Printf("[LOOP] Retrieved %d code chunks (%d tokens): %s", used, ctxbudget.EstimateTokens(text), strings.Join(paths, ", "))
+	return text
+}
+
+// symbolContext returns the Go declarations referenced in text. With refresh
+// the module is re-parsed first to pick up the Executioner's edits.
+func symbolContext(cfg *LoopConfig, text string, refresh bool) string {
+	if cfg.GoCode == nil {
+		return ""
+	}
+	if refresh {
+		if err := cfg.GoCode.Refresh(); err != nil {
+			log.Printf("[LOOP] Go code index refresh failed: %v", err)
+		}
+	}
+
+	snippets, symbols := cfg.GoCode.Context(text, cfg.GoContextTokens)
+	if len(symbols) > 0 {
+		log.Printf("[LOOP] Resolved Go symbols: %s", strings.Join(symbols, ", "))
+	}
+	return snippets
+}
+
+// recordUsage attaches the ledger's task totals to the task.
+func recordUsage(t *task.Task, cfg *LoopConfig) {
+	totals := cfg.Ledger.TaskTotals()
+	log.Printf("[LOOP] Task usage: %d calls, %d prompt + %d completion tokens, $%.4f",
+		totals.Calls, totals.PromptTokens, totals.CompletionTokens, totals.CostUSD)
+
+	usage := task.Usage{
+		Calls:            totals.Calls,
+		PromptTokens:     totals.PromptTokens,
+		CompletionTokens: totals.CompletionTokens,
+		CostUSD:          totals.CostUSD,
+	}
+	if cfg.Tasks != nil {
+		if err := cfg.Tasks.RecordUsage(t.ID, usage); err != nil {
+			log.Printf("[LOOP] Failed to record usage: %v", err)
+		}
+	}
+}
+
+func isBudgetError(err error) bool {
+	var budgetErr *agents.BudgetExceededError
+	return errors.As(err, &budgetErr)
+}
+
+// isRunBudgetError reports whether err means the whole run is out of budget.
+func isRunBudgetError(err error) bool {
+	var budgetErr *agents.BudgetExceededError
+	return errors.As(err, &budgetErr) && budgetErr.Scope == agents.BudgetScopeRun
+}
+
 // RunContinuous picks tasks from the task list and runs them through the loop.
 func RunContinuous(ctx context.Context, taskMgr *task.Manager, agentSet *AgentSet, cfg *LoopConfig) error {
 	for {
@@ -134,6 +560,9 @@ func RunContinuous(ctx context.Context, taskMgr *task.Manager, agentSet *AgentSe
 			if setErr := taskMgr.SetError(t.ID, err.Error()); setErr != nil {
 				log.Printf("[LOOP] Failed to record error: %v", setErr)
 			}
+			if isRunBudgetError(err) {
+				return err
+			}
 			continue
 		}
 
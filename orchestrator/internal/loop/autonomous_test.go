@@ -0,0 +1,190 @@
+package loop
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/agents"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/fakellm"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/patch"
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/task"
+)
+
+// newTestLoop wires the loop to two scripted fakellm servers, one for the
+// Engine (plan and native edits) and one for the Debugger, and runs
+// everything in a temporary project with the native backend.
+func newTestLoop(t *testing.T, testCommand string) (*AgentSet, *LoopConfig, *fakellm.Server, *fakellm.Server) {
+	t.Helper()
+	root := t.TempDir()
+
+	engineLLM, debuggerLLM := fakellm.NewScripted(), fakellm.NewScripted()
+	engineSrv, debuggerSrv := engineLLM.Start(), debuggerLLM.Start()
+	t.Cleanup(engineSrv.Close)
+	t.Cleanup(debuggerSrv.Close)
+
+	provider := func(url string) agents.Provider {
+		p, err := agents.NewProvider(agents.ProviderOpenAI, agents.ProviderConfig{APIKey: "test", APIURL: url, Model: "test-model"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	noRetry := agents.RetryPolicy{MaxAttempts: 1}
+
+	engine := agents.NewEngine(provider(engineSrv.URL))
+	engine.SetRetryPolicy(noRetry)
+	debugger := agents.NewDebugger(provider(debuggerSrv.URL))
+	debugger.SetRetryPolicy(noRetry)
+
+	agentSet := &AgentSet{
+		Engine:         engine,
+		Executioner:    agents.NewExecutioner(root),
+		Debugger:       debugger,
+		Backends:       map[string]agents.ExecutionBackend{agents.BackendNative: agents.NewNativeBackend(engine, root)},
+		DefaultBackend: agents.BackendNative,
+	}
+	cfg := &LoopConfig{MaxRetries: 3, TestCommand: testCommand, ProjectDir: root, DebugMemoryTurns: 3}
+	return agentSet, cfg, engineLLM, debuggerLLM
+}
+
+func debugReply(t *testing.T, fixType, content string) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{"analysis": "status.txt is wrong", "fix_type": fixType, "fix_content": content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func readStatus(t *testing.T, cfg *LoopConfig) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(cfg.ProjectDir, "status.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRunAutonomousLoopPatchFix(t *testing.T) {
+	agentSet, cfg, engineLLM, debuggerLLM := newTestLoop(t, "grep -q fixed status.txt")
+	engineLLM.Push(
+		fakellm.Response{Content: "1. Create status.txt"},
+		fakellm.Response{Content: patch.FormatBlock("status.txt", "broken")},
+	)
+	debuggerLLM.Push(fakellm.Response{Content: debugReply(t, agents.FixTypeCodePatch,
+		"--- a/status.txt\n+++ b/status.txt\n@@ -1 +1 @@\n-broken\n+fixed\n")})
+
+	tk := &task.Task{ID: "t1", Title: "Write the status file", Description: "status.txt must say fixed"}
+	if err := RunAutonomousLoop(context.Background(), tk, agentSet, cfg); err != nil {
+		t.Fatalf("RunAutonomousLoop: %v", err)
+	}
+
+	if got := readStatus(t, cfg); got != "fixed\n" {
+		t.Errorf("status.txt = %q", got)
+	}
+	if n := len(engineLLM.Requests()); n != 2 {
+		t.Errorf("Engine got %d requests, want plan and edit", n)
+	}
+	debugRequests := debuggerLLM.Requests()
+	if len(debugRequests) != 1 {
+		t.Fatalf("Debugger got %d requests, want 1", len(debugRequests))
+	}
+	last := debugRequests[0].Messages[len(debugRequests[0].Messages)-1].Content
+	if !strings.Contains(last, "grep -q fixed status.txt") {
+		t.Errorf("debug prompt does not show the test command:\n%s", last)
+	}
+}
+
+func TestRunAutonomousLoopCommandFix(t *testing.T) {
+	agentSet, cfg, engineLLM, debuggerLLM := newTestLoop(t, "grep -q fixed status.txt")
+	engineLLM.Push(
+		fakellm.Response{Content: "1. Create status.txt"},
+		fakellm.Response{Content: patch.FormatBlock("status.txt", "broken")},
+	)
+	debuggerLLM.Push(
+		fakellm.Response{Content: debugReply(t, agents.FixTypeCommand, "curl http://example.com -o status.txt")},
+		fakellm.Response{Content: debugReply(t, agents.FixTypeCommand, "echo fixed >> status.txt")},
+		fakellm.Response{Content: debugReply(t, agents.FixTypeCommand, "cp status.txt old.txt && mv old.txt status.txt")},
+	)
+	cfg.MaxRetries = 4
+
+	tk := &task.Task{ID: "t2", Title: "Write the status file"}
+	err := RunAutonomousLoop(context.Background(), tk, agentSet, cfg)
+	if err == nil {
+		t.Fatal("loop passed although no allowed command fixes the file")
+	}
+	if got := readStatus(t, cfg); got != "broken\n" {
+		t.Errorf("rejected commands changed status.txt to %q", got)
+	}
+
+	// The Debugger is told each rejection in its next round.
+	requests := debuggerLLM.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("Debugger got %d requests, want 3", len(requests))
+	}
+	var history strings.Builder
+	for _, m := range requests[2].Messages {
+		history.WriteString(m.Content)
+	}
+	for _, want := range []string{"curl is not an allowed command", "'>' is not allowed"} {
+		if !strings.Contains(history.String(), want) {
+			t.Errorf("third debug round does not mention %q", want)
+		}
+	}
+}
+
+func TestExecBudget(t *testing.T) {
+	agentSet := &AgentSet{DefaultBackend: agents.BackendClaude}
+	cfg := &LoopConfig{
+		ExecutionerContextTokens: 150000,
+		BackendContextTokens:     map[string]int{agents.BackendNative: 40000},
+	}
+
+	if got := execBudget(agentSet, &task.Task{}, cfg); got != 150000 {
+		t.Errorf("claude budget = %d", got)
+	}
+	if got := execBudget(agentSet, &task.Task{Backend: agents.BackendNative}, cfg); got != 40000 {
+		t.Errorf("task-selected native budget = %d", got)
+	}
+	agentSet.DefaultBackend = agents.BackendNative
+	if got := execBudget(agentSet, &task.Task{}, cfg); got != 40000 {
+		t.Errorf("default native budget = %d", got)
+	}
+}
+
+// costlyBackend reports a cost like the Claude CLI does.
+type costlyBackend struct{ cost float64 }
+
+func (b *costlyBackend) Name() string { return "costly" }
+
+func (b *costlyBackend) Execute(ctx context.Context, req agents.ExecRequest) (*agents.ExecutionSummary, error) {
+	return &agents.ExecutionSummary{CostUSD: b.cost, Usage: agents.Usage{PromptTokens: 100}}, nil
+}
+
+func TestExecuteRecordsBackendCost(t *testing.T) {
+	ledger := agents.NewLedger(nil, 1, 0)
+	ledger.StartTask("t1")
+	agentSet := &AgentSet{Backends: map[string]agents.ExecutionBackend{"costly": &costlyBackend{cost: 0.6}}, DefaultBackend: "costly"}
+	cfg := &LoopConfig{Ledger: ledger}
+	tk := &task.Task{ID: "t1"}
+
+	if _, err := execute(context.Background(), agentSet, tk, cfg, "do it"); err != nil {
+		t.Fatalf("first execute: %v", err)
+	}
+	if got := ledger.TaskTotals().CostUSD; got != 0.6 {
+		t.Errorf("task cost = %v, want 0.6", got)
+	}
+	if _, err := execute(context.Background(), agentSet, tk, cfg, "again"); !isBudgetError(err) {
+		t.Fatalf("second execute: %v, want a budget error", err)
+	}
+	if _, err := execute(context.Background(), agentSet, tk, cfg, "once more"); !isBudgetError(err) {
+		t.Fatalf("execute over budget ran: %v", err)
+	}
+	if calls := ledger.TaskTotals().Calls; calls != 2 {
+		t.Errorf("backend ran %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,45 @@
+package loop
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/agents"
+)
+
+// streamLogger logs streamed agent output line by line as it arrives.
+type streamLogger struct {
+	prefix string
+	buf    strings.Builder
+}
+
+func (l *streamLogger) write(delta string) {
+	l.buf.WriteString(delta)
+
+	text := l.buf.String()
+	idx := strings.LastIndexByte(text, '\n')
+	if idx < 0 {
+		return
+	}
+
+	for _, line := range strings.Split(text[:idx], "\n") {
+		log.Printf("%s %s", l.prefix, line)
+	}
+	l.buf.Reset()
+	l.buf.WriteString(text[idx+1:])
+}
+
+func (l *streamLogger) flush() {
+	if l.buf.Len() > 0 {
+		log.Printf("%s %s", l.prefix, l.buf.String())
+		l.buf.Reset()
+	}
+}
+
+// withStreamLog attaches a live logger for streamed agent output to ctx.
+// The returned function flushes any partial line.
+func withStreamLog(ctx context.Context, prefix string) (context.Context, func()) {
+	l := &streamLogger{prefix: prefix}
+	return agents.WithStreamHandler(ctx, l.write), l.flush
+}
@@ -0,0 +1,403 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxFuzz is how many context lines may be dropped from each end of a hunk
+// that does not match as written, like patch's fuzz factor.
+const maxFuzz = 2
+
+// HunkResult is the outcome of one hunk.
+type HunkResult struct {
+	Header  string
+	Applied bool
+	Line    int    // 1-based line the hunk was applied at
+	Offset  int    // Line minus the line the header named
+	Fuzz    int    // context lines dropped from each end to match
+	Loose   bool   // matched ignoring whitespace
+	Reason  string // why it failed
+}
+
+// FileResult is the outcome of one file's edit.
+type FileResult struct {
+	Path    string
+	Action  string // "patched", "created", "replaced", "deleted" or "" if nothing changed
+	Hunks   []HunkResult
+	Err     error // the whole edit was rejected
+	Written bool
+}
+
+// Report is the outcome of applying a set of edits.
+type Report struct {
+	Files []FileResult
+}
+
+// Counts returns the number of hunks (whole-file edits count as one) that
+// applied and failed.
+func (r *Report) Counts() (applied, failed int) {
+	for _, f := range r.Files {
+		if f.Err != nil {
+			failed += max(len(f.Hunks), 1)
+			continue
+		}
+		if len(f.Hunks) == 0 {
+			applied++
+			continue
+		}
+		for _, h := range f.Hunks {
+			if h.Applied {
+				applied++
+			} else {
+				failed++
+			}
+		}
+	}
+	return applied, failed
+}
+
+// OK reports whether every edit applied in full.
+func (r *Report) OK() bool {
+	_, failed := r.Counts()
+	return failed == 0
+}
+
+// Written returns the files that were changed on disk.
+func (r *Report) Written() []string {
+	var paths []string
+	for _, f := range r.Files {
+		if f.Written {
+			paths = append(paths, f.Path)
+		}
+	}
+	return paths
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, f := range r.Files {
+		if f.Err != nil {
+			fmt.Fprintf(&b, "%s: REJECTED: %v\n", f.Path, f.Err)
+			continue
+		}
+		if len(f.Hunks) == 0 {
+			fmt.Fprintf(&b, "%s: %s\n", f.Path, f.Action)
+			continue
+		}
+
+		applied := 0
+		for _, h := range f.Hunks {
+			if h.Applied {
+				applied++
+			}
+		}
+		fmt.Fprintf(&b, "%s: %d/%d hunks applied\n", f.Path, applied, len(f.Hunks))
+		for i, h := range f.Hunks {
+			fmt.Fprintf(&b, "  hunk %d %s: %s\n", i+1, h.Header, h.describe())
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (h HunkResult) describe() string {
+	if !h.Applied {
+		return "FAILED: " + h.Reason
+	}
+	var notes []string
+	if h.Offset != 0 {
+		notes = append(notes, fmt.Sprintf("offset %+d", h.Offset))
+	}
+	if h.Fuzz > 0 {
+		notes = append(notes, fmt.Sprintf("fuzz %d", h.Fuzz))
+	}
+	if h.Loose {
+		notes = append(notes, "ignoring whitespace")
+	}
+	if len(notes) == 0 {
+		return fmt.Sprintf("applied at line %d", h.Line)
+	}
+	return fmt.Sprintf("applied at line %d (%s)", h.Line, strings.Join(notes, ", "))
+}
+
+// Applier applies edits below a project root.
+type Applier struct {
+	root string
+}
+
+func NewApplier(root string) *Applier {
+	return &Applier{root: root}
+}
+
+// Apply applies each edit independently. Hunks that match are applied and
+// the rest reported, like `patch` leaving rejects; a file is written if any
+// of its hunks applied.
+func (a *Applier) Apply(edits []Edit) *Report {
+	report := &Report{}
+	for _, e := range edits {
+		report.Files = append(report.Files, a.apply(e))
+	}
+	return report
+}
+
+func (a *Applier) apply(e Edit) FileResult {
+	result := FileResult{Path: e.Path}
+	if e.Unterminated {
+		result.Err = fmt.Errorf("file block has no %q line, the reply was probably cut off; file left unchanged", FileBlockEnd)
+		return result
+	}
+	full, err := ResolveInRoot(a.root, e.Path)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	switch {
+	case e.Delete:
+		if err := os.Remove(full); err != nil {
+			result.Err = err
+			return result
+		}
+		result.Action, result.Written = "deleted", true
+		return result
+
+	case e.Replace:
+		if err := writeFile(full, e.Content); err != nil {
+			result.Err = err
+			return result
+		}
+		result.Action, result.Written = "replaced", true
+		if e.Content != "" {
+			result.Action = fmt.Sprintf("replaced (%d lines)", strings.Count(e.Content, "\n"))
+		}
+		return result
+	}
+
+	if len(e.Hunks) == 0 {
+		result.Err = fmt.Errorf("diff has no hunks")
+		return result
+	}
+
+	var lines []string
+	trailingNewline := true
+	data, err := os.ReadFile(full)
+	switch {
+	case err == nil && !e.Create:
+		text := string(data)
+		trailingNewline = strings.HasSuffix(text, "\n") || text == ""
+		if text = strings.TrimSuffix(text, "\n"); text != "" {
+			lines = strings.Split(text, "\n")
+		}
+	case err == nil && e.Create:
+		result.Err = fmt.Errorf("file already exists")
+		return result
+	case errors.Is(err, os.ErrNotExist) && e.Create:
+	default:
+		result.Err = err
+		return result
+	}
+
+	delta := 0
+	for _, h := range e.Hunks {
+		var hr HunkResult
+		lines, hr = applyHunk(lines, h, delta)
+		if hr.Applied {
+			delta += len(h.new()) - len(h.old()) + hr.Offset
+		}
+		result.Hunks = append(result.Hunks, hr)
+	}
+
+	applied := 0
+	for _, hr := range result.Hunks {
+		if hr.Applied {
+			applied++
+		}
+	}
+	if applied == 0 {
+		return result
+	}
+
+	content := strings.Join(lines, "\n")
+	if trailingNewline && content != "" {
+		content += "\n"
+	}
+	if err := writeFile(full, content); err != nil {
+		result.Err = err
+		return result
+	}
+	result.Action, result.Written = "patched", true
+	if e.Create {
+		result.Action = "created"
+	}
+	return result
+}
+
+// ResolveInRoot maps rel, which may also be an absolute path inside root, to
+// a path below root, following symlinks so they can't be used to escape.
+func ResolveInRoot(root, rel string) (string, error) {
+	if rel == "" {
+		return "", fmt.Errorf("empty path")
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	if realRoot, err := filepath.EvalSymlinks(root); err == nil {
+		root = realRoot
+	}
+
+	full := filepath.FromSlash(rel)
+	if !filepath.IsAbs(full) {
+		full = filepath.Join(root, full)
+	}
+	full = filepath.Clean(full)
+
+	// Resolve symlinks in the longest existing prefix of the path.
+	existing, rest := full, ""
+	for {
+		if real, err := filepath.EvalSymlinks(existing); err == nil {
+			full = filepath.Join(real, rest)
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+
+	inRoot, err := filepath.Rel(root, full)
+	if err != nil || inRoot == ".." || strings.HasPrefix(inRoot, ".."+string(filepath.Separator)) || inRoot == "." {
+		return "", fmt.Errorf("path %q is outside the project root", rel)
+	}
+	return full, nil
+}
+
+func writeFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return os.WriteFile(path, []byte(content), mode)
+}
+
+// applyHunk finds where h's old lines are in lines, nearest the expected
+// position first, and replaces them. It tries an exact match, then one that
+// ignores whitespace, then the same with up to maxFuzz context lines dropped
+// from each end.
+func applyHunk(lines []string, h Hunk, delta int) ([]string, HunkResult) {
+	result := HunkResult{Header: h.Header}
+	expected := 0
+	switch {
+	case h.OldStart > 0 && len(h.old()) == 0:
+		// "@@ -5,0 +6,2 @@" inserts after line 5.
+		expected = max(h.OldStart+delta, 0)
+	case h.OldStart > 0:
+		expected = max(h.OldStart-1+delta, 0)
+	}
+
+	for fuzz := 0; fuzz <= maxFuzz; fuzz++ {
+		hunkLines, ok := trimContext(h.Lines, fuzz)
+		if !ok {
+			break
+		}
+		old := Hunk{Lines: hunkLines}.old()
+		for _, loose := range []bool{false, true} {
+			at, found := find(lines, old, expected+fuzz, loose)
+			if !found {
+				continue
+			}
+			result.Applied = true
+			result.Line = at + 1
+			result.Fuzz = fuzz
+			result.Loose = loose
+			if h.OldStart > 0 {
+				result.Offset = at - (expected + fuzz)
+			}
+			return splice(lines, at, hunkLines), result
+		}
+	}
+
+	if h.OldStart > 0 {
+		result.Reason = fmt.Sprintf("context not found (expected near line %d)", expected+1)
+	} else {
+		result.Reason = "context not found"
+	}
+	if len(h.old()) > 0 {
+		result.Reason += fmt.Sprintf("; first expected line: %q", strings.TrimSpace(h.old()[0]))
+	}
+	return lines, result
+}
+
+// trimContext drops fuzz context lines from each end of a hunk, as long as
+// only context is dropped.
+func trimContext(hunkLines []string, fuzz int) ([]string, bool) {
+	if fuzz == 0 {
+		return hunkLines, true
+	}
+	if len(hunkLines) < 2*fuzz {
+		return nil, false
+	}
+	for i := 0; i < fuzz; i++ {
+		if hunkLines[i][0] != ' ' || hunkLines[len(hunkLines)-1-i][0] != ' ' {
+			return nil, false
+		}
+	}
+	return hunkLines[fuzz : len(hunkLines)-fuzz], true
+}
+
+// find returns the match position of old in lines closest to expected.
+func find(lines, old []string, expected int, loose bool) (int, bool) {
+	if len(old) == 0 {
+		// Pure insertion: trust the header.
+		return min(expected, len(lines)), true
+	}
+	last := len(lines) - len(old)
+	for d := 0; expected-d >= 0 || expected+d <= last; d++ {
+		if at := expected - d; at >= 0 && at <= last && matches(lines[at:at+len(old)], old, loose) {
+			return at, true
+		}
+		if at := expected + d; d > 0 && at >= 0 && at <= last && matches(lines[at:at+len(old)], old, loose) {
+			return at, true
+		}
+	}
+	return 0, false
+}
+
+func matches(have, want []string, loose bool) bool {
+	for i := range want {
+		if have[i] == want[i] {
+			continue
+		}
+		if !loose || strings.Join(strings.Fields(have[i]), " ") != strings.Join(strings.Fields(want[i]), " ") {
+			return false
+		}
+	}
+	return true
+}
+
+// splice replaces the hunk's old lines at position at with its new lines.
+// Context lines keep the file's own text, which may differ in whitespace.
+func splice(lines []string, at int, hunkLines []string) []string {
+	out := append([]string{}, lines[:at]...)
+	cursor := at
+	for _, l := range hunkLines {
+		switch l[0] {
+		case ' ':
+			out = append(out, lines[cursor])
+			cursor++
+		case '-':
+			cursor++
+		case '+':
+			out = append(out, l[1:])
+		}
+	}
+	return append(out, lines[cursor:]...)
+}
@@ -0,0 +1,210 @@
+package patch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const original = `package main
+
+import "fmt"
+
+func a() {
+	fmt.Println("a")
+}
+
+func b() {
+	fmt.Println("b")
+}
+
+func c() {
+	fmt.Println("c")
+}
+`
+
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func readFile(t *testing.T, root, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func applyText(t *testing.T, root, text string) *Report {
+	t.Helper()
+	edits, _ := Parse(text)
+	if len(edits) == 0 {
+		t.Fatalf("no edits parsed from %q", text)
+	}
+	return NewApplier(root).Apply(edits)
+}
+
+func TestApplyHunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		diff   string
+		want   string // replaces fmt.Println("b") in original
+		offset int
+		fuzz   int
+		loose  bool
+	}{
+		{
+			name: "exact",
+			diff: "@@ -9,3 +9,3 @@\n func b() {\n-\tfmt.Println(\"b\")\n+\tfmt.Println(\"B\")\n }\n",
+			want: "\tfmt.Println(\"B\")",
+		},
+		{
+			name:   "offset",
+			diff:   "@@ -2,3 +2,3 @@\n func b() {\n-\tfmt.Println(\"b\")\n+\tfmt.Println(\"B\")\n }\n",
+			want:   "\tfmt.Println(\"B\")",
+			offset: 7,
+		},
+		{
+			name: "fuzz",
+			diff: "@@ -8,5 +8,5 @@\n wrong context\n func b() {\n-\tfmt.Println(\"b\")\n+\tfmt.Println(\"B\")\n }\n wrong context\n",
+			want: "\tfmt.Println(\"B\")",
+			fuzz: 1,
+		},
+		{
+			name:  "whitespace",
+			diff:  "@@ -9,3 +9,3 @@\n func  b()  {\n-    fmt.Println(\"b\")\n+\tfmt.Println(\"B\")\n }\n",
+			want:  "\tfmt.Println(\"B\")",
+			loose: true,
+		},
+		{
+			name: "no line numbers",
+			diff: "@@\n func b() {\n-\tfmt.Println(\"b\")\n+\tfmt.Println(\"B\")\n }\n",
+			want: "\tfmt.Println(\"B\")",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeTree(t, map[string]string{"main.go": original})
+			report := applyText(t, root, "--- a/main.go\n+++ b/main.go\n"+tt.diff)
+			if !report.OK() {
+				t.Fatalf("report not OK:\n%s", report)
+			}
+
+			want := strings.Replace(original, "\tfmt.Println(\"b\")", tt.want, 1)
+			if got := readFile(t, root, "main.go"); got != want {
+				t.Errorf("file =\n%s\nwant\n%s", got, want)
+			}
+
+			h := report.Files[0].Hunks[0]
+			if h.Line != 9 && tt.fuzz == 0 || h.Offset != tt.offset || h.Fuzz != tt.fuzz || h.Loose != tt.loose {
+				t.Errorf("hunk result = %+v, want offset %d fuzz %d loose %v", h, tt.offset, tt.fuzz, tt.loose)
+			}
+		})
+	}
+}
+
+func TestApplyPartialAndFailed(t *testing.T) {
+	root := writeTree(t, map[string]string{"main.go": original})
+	report := applyText(t, root, "--- a/main.go\n+++ b/main.go\n"+
+		"@@ -5,3 +5,3 @@\n func a() {\n-\tfmt.Println(\"a\")\n+\tfmt.Println(\"A\")\n }\n"+
+		"@@ -13,3 +13,3 @@\n func z() {\n-\tfmt.Println(\"z\")\n+\tfmt.Println(\"Z\")\n }\n")
+
+	if applied, failed := report.Counts(); applied != 1 || failed != 1 {
+		t.Fatalf("Counts() = %d, %d, want 1, 1\n%s", applied, failed, report)
+	}
+	if report.OK() {
+		t.Error("OK() with a failed hunk")
+	}
+	got := readFile(t, root, "main.go")
+	if !strings.Contains(got, `fmt.Println("A")`) || !strings.Contains(got, `fmt.Println("c")`) {
+		t.Errorf("file =\n%s", got)
+	}
+	if !strings.Contains(report.String(), "FAILED: context not found") {
+		t.Errorf("report does not explain the failure:\n%s", report)
+	}
+
+	// A diff whose only hunk fails leaves the file alone.
+	report = applyText(t, root, "--- a/main.go\n+++ b/main.go\n@@\n-nothing like this\n+x\n")
+	if report.OK() || len(report.Written()) != 0 {
+		t.Errorf("failed diff reported %v, wrote %v", report.OK(), report.Written())
+	}
+}
+
+func TestApplyCreateDelete(t *testing.T) {
+	root := writeTree(t, map[string]string{"old.go": "package old\n"})
+
+	report := applyText(t, root, "--- /dev/null\n+++ b/pkg/new.go\n@@ -0,0 +1 @@\n+package pkg\n")
+	if !report.OK() || report.Files[0].Action != "created" {
+		t.Fatalf("create:\n%s", report)
+	}
+	if got := readFile(t, root, "pkg/new.go"); got != "package pkg\n" {
+		t.Errorf("created file = %q", got)
+	}
+
+	report = applyText(t, root, "--- /dev/null\n+++ b/old.go\n@@ -0,0 +1 @@\n+package other\n")
+	if report.OK() || readFile(t, root, "old.go") != "package old\n" {
+		t.Errorf("create over an existing file:\n%s", report)
+	}
+
+	report = applyText(t, root, "--- a/old.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-package old\n")
+	if !report.OK() || report.Files[0].Action != "deleted" {
+		t.Fatalf("delete:\n%s", report)
+	}
+	if _, err := os.Stat(filepath.Join(root, "old.go")); !os.IsNotExist(err) {
+		t.Errorf("old.go still exists: %v", err)
+	}
+}
+
+func TestApplyFileBlocks(t *testing.T) {
+	root := writeTree(t, map[string]string{"main.go": original})
+
+	report := applyText(t, root, FormatBlock("main.go", "package main\n"))
+	if !report.OK() || readFile(t, root, "main.go") != "package main\n" {
+		t.Fatalf("whole-file block:\n%s", report)
+	}
+
+	// A reply cut off inside a block must not truncate the file.
+	root = writeTree(t, map[string]string{"main.go": original})
+	report = applyText(t, root, "--- FILE: main.go\npackage main\n\nfunc a() {\n")
+	if report.OK() || len(report.Written()) != 0 {
+		t.Errorf("unterminated block applied:\n%s", report)
+	}
+	if got := readFile(t, root, "main.go"); got != original {
+		t.Errorf("unterminated block changed the file to %q", got)
+	}
+}
+
+func TestApplyOutsideRoot(t *testing.T) {
+	outside := writeTree(t, map[string]string{"secret.go": "package secret\n"})
+	root := writeTree(t, nil)
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"../x.go", filepath.Join(outside, "secret.go"), "link/secret.go", "link/new.go", "."} {
+		report := NewApplier(root).Apply([]Edit{{Path: path, Replace: true, Content: "package evil\n"}})
+		if report.OK() {
+			t.Errorf("%s: edit outside the root was applied", path)
+		}
+	}
+	if got := readFile(t, outside, "secret.go"); got != "package secret\n" {
+		t.Errorf("file outside the root changed to %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.go")); !os.IsNotExist(err) {
+		t.Error("file created outside the root")
+	}
+}
@@ -0,0 +1,174 @@
+// Package patch applies model-written edits to a project tree: unified diffs,
+// matched fuzzily hunk by hunk, and whole-file replacement blocks. Every edit
+// is confined to the project root and every hunk's outcome is reported.
+package patch
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Whole-file block markers.
+const (
+	FileBlockStart = "--- FILE: "
+	FileBlockEnd   = "--- END FILE"
+)
+
+// Edit is the change to one file.
+type Edit struct {
+	Path    string
+	Replace bool   // Content replaces the whole file
+	Content string // for Replace
+	Create  bool   // diff from /dev/null
+	Delete  bool   // diff to /dev/null
+	Hunks   []Hunk
+
+	// Unterminated is set on a whole-file block missing its end marker,
+	// usually a reply cut off at the token limit. It is never applied.
+	Unterminated bool
+}
+
+// Hunk is one @@ section of a unified diff.
+type Hunk struct {
+	Header   string
+	OldStart int // 0 when the header has no line numbers
+	Lines    []string
+}
+
+// old returns the lines the hunk expects to find; new the lines it leaves.
+func (h Hunk) old() []string { return h.without('+') }
+func (h Hunk) new() []string { return h.without('-') }
+
+func (h Hunk) without(drop byte) []string {
+	var out []string
+	for _, l := range h.Lines {
+		if l[0] != drop {
+			out = append(out, l[1:])
+		}
+	}
+	return out
+}
+
+var indexRe = regexp.MustCompile(`^index [0-9a-f]+\.\.[0-9a-f]+`)
+
+var hunkRe = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@|^@@ .*@@|^@@$`)
+
+// Parse extracts unified diffs and whole-file blocks from text and returns
+// them with the remaining prose. Markdown fences around diffs are ignored.
+// Hunk line counts are not trusted, since models often get them wrong.
+func Parse(text string) ([]Edit, string) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var edits []Edit
+	var prose []string
+	var current *Edit
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, FileBlockStart):
+			end := i + 1
+			for end < len(lines) && strings.TrimSpace(lines[end]) != FileBlockEnd && !strings.HasPrefix(lines[end], FileBlockStart) {
+				end++
+			}
+			terminated := end < len(lines) && strings.TrimSpace(lines[end]) == FileBlockEnd
+			edits = append(edits, Edit{
+				Path:         strings.TrimSpace(strings.TrimPrefix(line, FileBlockStart)),
+				Replace:      true,
+				Content:      blockContent(lines[i+1 : end]),
+				Unterminated: !terminated,
+			})
+			current = nil
+			if !terminated {
+				end-- // a following block starts on lines[end]
+			}
+			i = end
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			oldPath, newPath := diffPath(line[4:]), diffPath(lines[i+1][4:])
+			edit := Edit{Path: newPath}
+			switch {
+			case oldPath == "/dev/null":
+				edit.Create = true
+			case newPath == "/dev/null":
+				edit.Path, edit.Delete = oldPath, true
+			}
+			edits = append(edits, edit)
+			current = &edits[len(edits)-1]
+			i++
+
+		case current != nil && strings.HasPrefix(line, "@@"):
+			hunk := Hunk{Header: line}
+			if m := hunkRe.FindStringSubmatch(line); m != nil && m[1] != "" {
+				hunk.OldStart, _ = strconv.Atoi(m[1])
+			}
+			i = readHunk(lines, i+1, &hunk) - 1
+			current.Hunks = append(current.Hunks, hunk)
+
+		case strings.HasPrefix(line, "diff --git "), indexRe.MatchString(line), strings.HasPrefix(line, "```"):
+			// Diff preamble and fences carry nothing we need.
+
+		default:
+			current = nil
+			prose = append(prose, line)
+		}
+	}
+	return edits, strings.TrimSpace(strings.Join(prose, "\n"))
+}
+
+// readHunk reads hunk lines starting at lines[i] and returns the index of the
+// first line after the hunk. Blank lines count as blank context, as models
+// often drop the leading space, but trailing ones are trimmed.
+func readHunk(lines []string, i int, hunk *Hunk) int {
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, FileBlockStart) || strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			break
+		}
+		if line == "" {
+			hunk.Lines = append(hunk.Lines, " ")
+			continue
+		}
+		if line[0] == '\\' {
+			continue // "\ No newline at end of file"
+		}
+		if line[0] != ' ' && line[0] != '+' && line[0] != '-' {
+			break
+		}
+		hunk.Lines = append(hunk.Lines, line)
+	}
+	for len(hunk.Lines) > 0 && hunk.Lines[len(hunk.Lines)-1] == " " {
+		hunk.Lines = hunk.Lines[:len(hunk.Lines)-1]
+	}
+	return i
+}
+
+// diffPath strips the a/ b/ prefixes and any timestamp from a ---/+++ path.
+func diffPath(s string) string {
+	if tab := strings.IndexByte(s, '\t'); tab >= 0 {
+		s = s[:tab]
+	}
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return s
+	}
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		s = s[2:]
+	}
+	return s
+}
+
+// blockContent joins a whole-file block's lines, removing a markdown fence
+// wrapped around them.
+func blockContent(body []string) string {
+	if len(body) >= 2 && strings.HasPrefix(body[0], "```") && strings.TrimSpace(body[len(body)-1]) == "```" {
+		body = body[1 : len(body)-1]
+	}
+	return strings.Join(body, "\n") + "\n"
+}
+
+// FormatBlock renders a whole-file block.
+func FormatBlock(path, content string) string {
+	return fmt.Sprintf("%s%s\n%s\n%s\n", FileBlockStart, path, strings.TrimSuffix(content, "\n"), FileBlockEnd)
+}
@@ -0,0 +1,103 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDiff(t *testing.T) {
+	text := "Fix the greeting.\n" +
+		"```diff\n" +
+		"diff --git a/main.go b/main.go\n" +
+		"index 1234abc..5678def 100644\n" +
+		"--- a/main.go\n" +
+		"+++ b/main.go\n" +
+		"@@ -3,3 +3,3 @@ func main() {\n" +
+		" func main() {\n" +
+		"-\tprintln(\"hi\")\n" +
+		"+\tprintln(\"hello\")\n" +
+		"\n" +
+		"--- /dev/null\n" +
+		"+++ b/new.go\n" +
+		"@@ -0,0 +1 @@\n" +
+		"+package main\n" +
+		"--- a/old.go\n" +
+		"+++ /dev/null\n" +
+		"@@ -1 +0,0 @@\n" +
+		"-package main\n" +
+		"```\n" +
+		"Done."
+
+	edits, prose := Parse(text)
+	if prose != "Fix the greeting.\nDone." {
+		t.Errorf("prose = %q", prose)
+	}
+	if len(edits) != 3 {
+		t.Fatalf("got %d edits, want 3: %+v", len(edits), edits)
+	}
+
+	main := edits[0]
+	if main.Path != "main.go" || main.Create || main.Delete || len(main.Hunks) != 1 {
+		t.Fatalf("main.go edit = %+v", main)
+	}
+	h := main.Hunks[0]
+	if h.OldStart != 3 {
+		t.Errorf("OldStart = %d, want 3", h.OldStart)
+	}
+	if want := []string{"func main() {", "\tprintln(\"hi\")"}; !reflect.DeepEqual(h.old(), want) {
+		t.Errorf("old() = %q, want %q", h.old(), want)
+	}
+	if want := []string{"func main() {", "\tprintln(\"hello\")"}; !reflect.DeepEqual(h.new(), want) {
+		t.Errorf("new() = %q, want %q", h.new(), want)
+	}
+
+	if !edits[1].Create || edits[1].Path != "new.go" {
+		t.Errorf("create edit = %+v", edits[1])
+	}
+	if !edits[2].Delete || edits[2].Path != "old.go" {
+		t.Errorf("delete edit = %+v", edits[2])
+	}
+}
+
+func TestParseFileBlocks(t *testing.T) {
+	text := "--- FILE: a.go\n" +
+		"```go\n" +
+		"package a\n" +
+		"```\n" +
+		"--- END FILE\n" +
+		"--- FILE: b.go\n" +
+		"package b\n" +
+		"--- FILE: c.go\n" +
+		"package main\n" +
+		"\n" +
+		"func a() {\n"
+
+	edits, _ := Parse(text)
+	if len(edits) != 3 {
+		t.Fatalf("got %d edits, want 3: %+v", len(edits), edits)
+	}
+
+	tests := []struct {
+		path         string
+		content      string
+		unterminated bool
+	}{
+		{"a.go", "package a\n", false},
+		{"b.go", "package b\n", true},
+		{"c.go", "package main\n\nfunc a() {\n\n", true},
+	}
+	for i, tt := range tests {
+		e := edits[i]
+		if e.Path != tt.path || !e.Replace || e.Content != tt.content || e.Unterminated != tt.unterminated {
+			t.Errorf("edit %d = %+v, want path %s content %q unterminated %v", i, e, tt.path, tt.content, tt.unterminated)
+		}
+	}
+}
+
+func TestFormatBlockRoundTrip(t *testing.T) {
+	content := "package main\n\nfunc main() {}\n"
+	edits, _ := Parse(FormatBlock("cmd/main.go", content))
+	if len(edits) != 1 || edits[0].Path != "cmd/main.go" || edits[0].Content != content || edits[0].Unterminated {
+		t.Fatalf("Parse(FormatBlock) = %+v", edits)
+	}
+}
@@ -0,0 +1,190 @@
+// Package prompts renders the agent and loop prompts from text/template
+// files. Defaults are embedded in the binary; a project can override any of
+// them by placing a file with the same name in its prompts/ directory.
+package prompts
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/task"
+)
+
+//go:embed templates/*.tmpl
+var defaults embed.FS
+
+// Template names.
+const (
+	EngineSystem   = "engine_system"
+	DebuggerSystem = "debugger_system"
+	EditSystem     = "edit_system"
+	Plan           = "plan"
+	Execute        = "execute"
+	Debug          = "debug"
+	Fix            = "fix"
+)
+
+// Data is the context every template is rendered with. Fields that do not
+// apply to a phase are left zero.
+type Data struct {
+	Task        *task.Task
+	Plan        string
+	ExecResult  string
+	TestCommand string
+	TestOutput  string
+	Attempt     int
+	MaxAttempts int
+	Fix         *FixData
+	Context     string // code retrieved from the project for this prompt
+	Symbols     string // Go declarations referenced by the task or errors
+	Diagnostics string // parsed compiler errors with surrounding source
+
+	AllowedCommands []string // programs a Debugger command fix may run
+}
+
+// FixData describes a fix proposed by the Debugger.
+type FixData struct {
+	Analysis   string
+	FixType    string
+	FixContent string
+}
+
+// Template is a parsed prompt template and where it came from.
+type Template struct {
+	Name    string
+	Source  string // "embedded" or the override file path
+	Version string // short hash of the template source
+	tmpl    *template.Template
+}
+
+// Set holds all prompt templates for a run.
+type Set struct {
+	templates map[string]*Template
+}
+
+// Default returns the embedded templates.
+func Default() *Set {
+	s, err := Load("")
+	if err != nil {
+		panic(fmt.Sprintf("prompts: embedded templates: %v", err))
+	}
+	return s
+}
+
+// System renders an embedded system prompt. Agents use it for their
+// defaults; it panics if the embedded template is broken.
+func System(name string) string {
+	return SystemWith(name, Data{})
+}
+
+// SystemWith is System for prompts that describe settings, such as the
+// Debugger's allowed commands.
+func SystemWith(name string, data Data) string {
+	prompt, err := Default().Render(name, data)
+	if err != nil {
+		panic(fmt.Sprintf("prompts: %v", err))
+	}
+	return prompt
+}
+
+// Load parses the embedded templates and applies overrides from
+// <projectDir>/prompts/<name>.tmpl. An empty projectDir skips overrides.
+func Load(projectDir string) (*Set, error) {
+	s := &Set{templates: make(map[string]*Template)}
+
+	entries, err := fs.Glob(defaults, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range entries {
+		src, err := defaults.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.add(strings.TrimSuffix(filepath.Base(path), ".tmpl"), "embedded", string(src)); err != nil {
+			return nil, err
+		}
+	}
+
+	if projectDir == "" {
+		return s, nil
+	}
+
+	overrides, err := filepath.Glob(filepath.Join(projectDir, "prompts", "*.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("list prompt overrides: %w", err)
+	}
+	for _, path := range overrides {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read prompt override: %w", err)
+		}
+		if err := s.add(strings.TrimSuffix(filepath.Base(path), ".tmpl"), path, string(src)); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *Set) add(name, source, src string) error {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(src)
+	if err != nil {
+		return fmt.Errorf("parse prompt %s (%s): %w", name, source, err)
+	}
+
+	sum := sha256.Sum256([]byte(src))
+	s.templates[name] = &Template{
+		Name:    name,
+		Source:  source,
+		Version: hex.EncodeToString(sum[:6]),
+		tmpl:    tmpl,
+	}
+	return nil
+}
+
+// Get returns the named template.
+func (s *Set) Get(name string) (*Template, error) {
+	t, ok := s.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown prompt template %q", name)
+	}
+	return t, nil
+}
+
+// Templates returns all templates sorted by name.
+func (s *Set) Templates() []*Template {
+	list := make([]*Template, 0, len(s.templates))
+	for _, t := range s.templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Render executes the named template with data.
+func (s *Set) Render(name string, data Data) (string, error) {
+	t, err := s.Get(name)
+	if err != nil {
+		return "", err
+	}
+	return t.Render(data)
+}
+
+// Render executes the template with data and trims surrounding whitespace.
+func (t *Template) Render(data Data) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("render prompt %s: %w", t.Name, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -0,0 +1,411 @@
+// Package retrieval builds a lexical index over the project tree so the
+// agents see relevant code instead of just the task text. Files are split
+// into line chunks and ranked with BM25; query terms missing from the index
+// are expanded to similar indexed terms by trigram overlap.
+package retrieval
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ahmetk3436/EcoMonitor-AI/orchestrator/internal/ctxbudget"
+)
+
+const (
+	chunkLines  = 50
+	maxFileSize = 512 * 1024
+	sniffBytes  = 8000
+
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	minTrigramSim = 0.5
+	maxExpansions = 3
+)
+
+// skipDirs are never indexed or searched, ignored or not.
+var skipDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+	".expo":        true,
+	"dist":         true,
+	"build":        true,
+}
+
+// SkipDir reports whether a directory of that name holds dependencies, build
+// output or VCS data that no agent should search.
+func SkipDir(name string) bool {
+	return skipDirs[name]
+}
+
+// secretNames are files that hold credentials whatever their directory:
+// SSH private keys and auth files of package managers and tools.
+var secretNames = map[string]bool{
+	"id_rsa":           true,
+	"id_dsa":           true,
+	"id_ecdsa":         true,
+	"id_ed25519":       true,
+	"credentials.json": true,
+	".npmrc":           true,
+	".yarnrc.yml":      true,
+	".netrc":           true,
+	".pgpass":          true,
+	".git-credentials": true,
+	".htpasswd":        true,
+}
+
+// secretExts are extensions of private keys and key stores.
+var secretExts = map[string]bool{
+	".pem":      true,
+	".key":      true,
+	".p12":      true,
+	".pfx":      true,
+	".jks":      true,
+	".keystore": true,
+}
+
+// IsSecret reports whether a file name looks like it holds credentials:
+// .env files other than .env.example, private keys, key stores and auth
+// files such as .npmrc. Such files are never indexed or shown to a model,
+// ignored or not.
+func IsSecret(name string) bool {
+	switch {
+	case name == ".env.example":
+		return false
+	case name == ".env", strings.HasPrefix(name, ".env."):
+		return true
+	}
+	return secretNames[name] || secretExts[strings.ToLower(filepath.Ext(name))]
+}
+
+// Chunk is a run of lines from one file.
+type Chunk struct {
+	Path      string // relative to the index root
+	StartLine int
+	EndLine   int
+	Text      string
+	Score     float64
+
+	terms map[string]int
+	len   int
+}
+
+type fileEntry struct {
+	modTime time.Time
+	size    int64
+	chunks  []*Chunk
+}
+
+type posting struct {
+	chunk *Chunk
+	tf    int
+}
+
+// Index is a BM25 index over the text files below a root directory.
+type Index struct {
+	root     string
+	files    map[string]*fileEntry
+	chunks   []*Chunk
+	postings map[string][]posting
+	trigrams map[string][]string
+	avgLen   float64
+}
+
+// Build walks root, honouring .gitignore files and skipping binaries, and
+// indexes every text file.
+func Build(root string) (*Index, error) {
+	idx := &Index{root: root, files: make(map[string]*fileEntry)}
+	if err := idx.Refresh(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Refresh re-walks the tree, re-reading only files whose size or mtime
+// changed, and rebuilds the postings.
+func (idx *Index) Refresh() error {
+	files := make(map[string]*fileEntry)
+	if err := idx.walk(idx.root, ".", nil, files); err != nil {
+		return fmt.Errorf("index %s: %w", idx.root, err)
+	}
+	idx.files = files
+
+	idx.chunks = nil
+	idx.postings = make(map[string][]posting)
+	total := 0
+	for _, f := range files {
+		for _, c := range f.chunks {
+			idx.chunks = append(idx.chunks, c)
+			total += c.len
+			for term, tf := range c.terms {
+				idx.postings[term] = append(idx.postings[term], posting{chunk: c, tf: tf})
+			}
+		}
+	}
+	if len(idx.chunks) > 0 {
+		idx.avgLen = float64(total) / float64(len(idx.chunks))
+	}
+
+	idx.trigrams = make(map[string][]string)
+	for term := range idx.postings {
+		for _, tri := range trigramsOf(term) {
+			idx.trigrams[tri] = append(idx.trigrams[tri], term)
+		}
+	}
+	return nil
+}
+
+func (idx *Index) walk(dir, rel string, rules []ignoreRule, files map[string]*fileEntry) error {
+	rules = append(rules[:len(rules):len(rules)], loadIgnore(dir, rel)...)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		entryRel := filepath.Join(rel, e.Name())
+		if e.IsDir() {
+			if SkipDir(e.Name()) || ignored(rules, entryRel, true) {
+				continue
+			}
+			if err := idx.walk(path, entryRel, rules, files); err != nil {
+				return err
+			}
+			continue
+		}
+		if !e.Type().IsRegular() || IsSecret(e.Name()) || ignored(rules, entryRel, false) {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil || info.Size() > maxFileSize {
+			continue
+		}
+		key := filepath.ToSlash(entryRel)
+		if old, ok := idx.files[key]; ok && old.size == info.Size() && old.modTime.Equal(info.ModTime()) {
+			files[key] = old
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil || isBinary(data) {
+			continue
+		}
+		files[key] = &fileEntry{modTime: info.ModTime(), size: info.Size(), chunks: chunkFile(key, data)}
+	}
+	return nil
+}
+
+// isBinary sniffs the start of a file for NUL bytes or invalid UTF-8.
+func isBinary(data []byte) bool {
+	head := data[:min(len(data), sniffBytes)]
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	// The sniffed prefix may end mid-rune.
+	for i := 0; i < utf8.UTFMax && len(head) > 0 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	return !utf8.Valid(head)
+}
+
+func chunkFile(path string, data []byte) []*Chunk {
+	lines := strings.Split(string(data), "\n")
+	pathTerms := tokenize(path)
+
+	var chunks []*Chunk
+	for start := 0; start < len(lines); start += chunkLines {
+		end := min(start+chunkLines, len(lines))
+		text := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		c := &Chunk{Path: path, StartLine: start + 1, EndLine: end, Text: text, terms: make(map[string]int)}
+		for _, term := range append(tokenize(text), pathTerms...) {
+			c.terms[term]++
+			c.len++
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks
+}
+
+var identRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// tokenize lowercases identifiers and also emits their camelCase and
+// snake_case parts, so "HandleSensorData" matches "sensor data".
+func tokenize(s string) []string {
+	var terms []string
+	for _, ident := range identRe.FindAllString(s, -1) {
+		parts := splitIdent(ident)
+		if len(parts) > 1 {
+			if t := strings.ToLower(ident); len(t) > 1 && !stopWords[t] {
+				terms = append(terms, t)
+			}
+		}
+		for _, p := range parts {
+			if t := strings.ToLower(p); len(t) > 1 && !stopWords[t] {
+				terms = append(terms, t)
+			}
+		}
+	}
+	return terms
+}
+
+func splitIdent(ident string) []string {
+	var parts []string
+	for _, word := range strings.Split(ident, "_") {
+		runes := []rune(word)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			upper := unicode.IsUpper(runes[i])
+			prevLower := !unicode.IsUpper(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if upper && (prevLower || nextLower) {
+				parts = append(parts, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			parts = append(parts, string(runes[start:]))
+		}
+	}
+	return parts
+}
+
+var stopWords = map[string]bool{
+	"the": true, "and": true, "or": true, "to": true, "of": true, "in": true,
+	"a": true, "an": true, "is": true, "it": true, "for": true, "on": true,
+	"be": true, "with": true, "as": true, "by": true, "this": true, "that": true,
+	"if": true, "err": true, "nil": true, "return": true, "func": true,
+	"const": true, "var": true, "import": true, "package": true,
+}
+
+func trigramsOf(term string) []string {
+	padded := " " + term + " "
+	var out []string
+	for i := 0; i+3 <= len(padded); i++ {
+		out = append(out, padded[i:i+3])
+	}
+	return out
+}
+
+// expand maps a query term to the indexed terms it should match, with
+// weights. Unknown terms are matched to the most similar indexed terms by
+// trigram Jaccard similarity.
+func (idx *Index) expand(term string) map[string]float64 {
+	if _, ok := idx.postings[term]; ok {
+		return map[string]float64{term: 1}
+	}
+	if len(term) < 4 {
+		return nil
+	}
+
+	query := trigramsOf(term)
+	shared := make(map[string]int)
+	for _, tri := range query {
+		for _, cand := range idx.trigrams[tri] {
+			shared[cand]++
+		}
+	}
+
+	type match struct {
+		term string
+		sim  float64
+	}
+	var matches []match
+	for cand, n := range shared {
+		sim := float64(n) / float64(len(query)+len(trigramsOf(cand))-n)
+		if sim >= minTrigramSim {
+			matches = append(matches, match{cand, sim})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].sim != matches[j].sim {
+			return matches[i].sim > matches[j].sim
+		}
+		return matches[i].term < matches[j].term
+	})
+
+	out := make(map[string]float64)
+	for _, m := range matches[:min(len(matches), maxExpansions)] {
+		out[m.term] = m.sim
+	}
+	return out
+}
+
+// Stats returns the number of indexed files and chunks.
+func (idx *Index) Stats() (files, chunks int) {
+	return len(idx.files), len(idx.chunks)
+}
+
+// Search returns the k chunks that best match query, best first.
+func (idx *Index) Search(query string, k int) []Chunk {
+	if len(idx.chunks) == 0 || k <= 0 {
+		return nil
+	}
+
+	weights := make(map[string]float64)
+	for _, term := range tokenize(query) {
+		for t, w := range idx.expand(term) {
+			weights[t] = max(weights[t], w)
+		}
+	}
+
+	n := float64(len(idx.chunks))
+	scores := make(map[*Chunk]float64)
+	for term, w := range weights {
+		postings := idx.postings[term]
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, p := range postings {
+			tf := float64(p.tf)
+			norm := tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(p.chunk.len)/idx.avgLen))
+			scores[p.chunk] += w * idf * norm
+		}
+	}
+
+	results := make([]Chunk, 0, len(scores))
+	for c, score := range scores {
+		r := *c
+		r.Score = score
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Path != results[j].Path {
+			return results[i].Path < results[j].Path
+		}
+		return results[i].StartLine < results[j].StartLine
+	})
+	return results[:min(len(results), k)]
+}
+
+// Format renders chunks, best first, as long as they fit maxTokens.
+func Format(chunks []Chunk, maxTokens int) (string, int) {
+	var b strings.Builder
+	used := 0
+	for _, c := range chunks {
+		block := fmt.Sprintf("--- %s:%d-%d ---\n%s\n", c.Path, c.StartLine, c.EndLine, c.Text)
+		if maxTokens > 0 && ctxbudget.EstimateTokens(b.String()+block) > maxTokens {
+			break
+		}
+		b.WriteString(block)
+		used++
+	}
+	return b.String(), used
+}
@@ -17,6 +17,17 @@ type Task struct {
 	CreatedAt   string   `json:"created_at"`
 	UpdatedAt   string   `json:"updated_at"`
 	Error       string   `json:"error,omitempty"`
+	Usage       *Usage   `json:"usage,omitempty"`
+	SessionID   string   `json:"session_id,omitempty"` // Executioner session to resume
+	Backend     string   `json:"backend,omitempty"`    // execution backend; empty uses the default
+}
+
+// Usage is the LLM token usage and cost accumulated by a task across runs.
+type Usage struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
 }
 
 type TaskList struct {
@@ -134,6 +145,50 @@ func (m *Manager) SetError(id, errMsg string) error {
 	return fmt.Errorf("task %s not found", id)
 }
 
+// SetSessionID stores the Executioner session a task's work happened in.
+func (m *Manager) SetSessionID(id, sessionID string) error {
+	list, err := m.Load()
+	if err != nil {
+		return err
+	}
+
+	for i, t := range list.Tasks {
+		if t.ID == id {
+			list.Tasks[i].SessionID = sessionID
+			list.Tasks[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+			return m.Save(list)
+		}
+	}
+
+	return fmt.Errorf("task %s not found", id)
+}
+
+// RecordUsage adds the usage of a run to the task's running totals.
+func (m *Manager) RecordUsage(id string, u Usage) error {
+	list, err := m.Load()
+	if err != nil {
+		return err
+	}
+
+	for i, t := range list.Tasks {
+		if t.ID == id {
+			total := list.Tasks[i].Usage
+			if total == nil {
+				total = &Usage{}
+				list.Tasks[i].Usage = total
+			}
+			total.Calls += u.Calls
+			total.PromptTokens += u.PromptTokens
+			total.CompletionTokens += u.CompletionTokens
+			total.CostUSD += u.CostUSD
+			list.Tasks[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+			return m.Save(list)
+		}
+	}
+
+	return fmt.Errorf("task %s not found", id)
+}
+
 func (m *Manager) AddTask(t Task) error {
 	list, err := m.Load()
 	if err != nil {